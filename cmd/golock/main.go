@@ -0,0 +1,224 @@
+// Copyright 2015 realglobe, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// flock(1) 風に、ロックを取ってからコマンドを実行する。
+//
+//	golock [options] <lock file> <command> [<argument>...]
+//
+// ロックは lock パッケージと同じ方式なので、Go のプログラムとロックファイルを共有できる。
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"os/signal"
+	"strings"
+	"syscall"
+	"time"
+	"unsafe"
+
+	"github.com/realglobe-Inc/go-lib/erro"
+	"github.com/realglobe-Inc/go-lib/lock"
+)
+
+const (
+	// ロックが取れなかったときの終了コードの初期値。flock(1) と同じ。
+	defaultConflictCode = 1
+	// 引数がおかしいとき。sysexits.h の EX_USAGE。
+	usageCode = 64
+	// それ以外で失敗したとき。sysexits.h の EX_OSERR。
+	errCode = 71
+)
+
+// ロックファイルを子プロセスに渡すときのファイルディスクリプタ番号。
+const passedFd = 3
+
+// 渡したファイルディスクリプタ番号を子プロセスに教える環境変数。
+const fdEnv = "GOLOCK_FD"
+
+func main() {
+	os.Exit(run(os.Args[1:], os.Stdin, os.Stdout, os.Stderr))
+}
+
+// 終了コードを返す。
+func run(args []string, stdin io.Reader, stdout, stderr io.Writer) int {
+	flags := flag.NewFlagSet("golock", flag.ContinueOnError)
+	flags.SetOutput(stderr)
+	flags.Usage = func() {
+		fmt.Fprintln(stderr, "Usage: golock [options] <lock file> <command> [<argument>...]")
+		flags.PrintDefaults()
+	}
+	shared := flags.Bool("s", false, "Obtain a shared lock instead of an exclusive lock")
+	nonblock := flags.Bool("n", false, "Fail rather than wait if the lock is busy")
+	wait := flags.Duration("w", 0, "Fail if the lock is not obtained within this duration (0 means forever)")
+	conflictCode := flags.Int("E", defaultConflictCode, "Exit code used when the lock is busy")
+	owner := flags.Bool("owner", false, "Record the owner in the lock file while holding an exclusive lock")
+	passFd := flags.Bool("pass-fd", false, "Pass the lock file to the command as file descriptor "+fmt.Sprint(passedFd)+" and set "+fdEnv)
+	if err := flags.Parse(args); err != nil {
+		return usageCode
+	} else if flags.NArg() < 2 {
+		flags.Usage()
+		return usageCode
+	} else if *owner && *shared {
+		fmt.Fprintln(stderr, "-owner cannot be used with a shared lock")
+		return usageCode
+	} else if *nonblock && *wait != 0 {
+		fmt.Fprintln(stderr, "-n cannot be used with -w")
+		return usageCode
+	}
+	path := flags.Arg(0)
+	cmdArgs := flags.Args()[1:]
+
+	locker, err := acquire(path, *shared, *nonblock, *wait)
+	if err != nil {
+		fmt.Fprintln(stderr, err)
+		return errCode
+	} else if locker == nil {
+		// ロックできなかった。
+		return *conflictCode
+	}
+	defer locker.Unlock()
+
+	if *owner {
+		if err := writeOwner(locker.File(), cmdArgs); err != nil {
+			fmt.Fprintln(stderr, err)
+			return errCode
+		}
+		// 解放後に古い情報が残らないように。
+		defer clearOwner(locker.File())
+	}
+
+	cmd := exec.Command(cmdArgs[0], cmdArgs[1:]...)
+	cmd.Stdin = stdin
+	cmd.Stdout = stdout
+	cmd.Stderr = stderr
+	if *passFd {
+		// ExtraFiles の先頭が 3 番になる。
+		// 渡さない場合は、close-on-exec なので子プロセスには見えない。
+		cmd.ExtraFiles = []*os.File{locker.File()}
+		cmd.Env = append(os.Environ(), fdEnv+"="+fmt.Sprint(passedFd))
+	}
+
+	return execute(cmd, stderr)
+}
+
+func acquire(path string, shared, nonblock bool, wait time.Duration) (*lock.Locker, error) {
+	switch {
+	case nonblock && shared:
+		return lock.TryLockShared(path)
+	case nonblock:
+		return lock.TryLock(path)
+	case wait > 0 && shared:
+		return lock.WaitLockShared(path, wait)
+	case wait > 0:
+		return lock.WaitLock(path, wait)
+	case shared:
+		return lock.LockShared(path)
+	default:
+		return lock.Lock(path)
+	}
+}
+
+// ロックファイルに所有者情報を書き込む。
+func writeOwner(file *os.File, cmdArgs []string) error {
+	host, err := os.Hostname()
+	if err != nil {
+		return erro.Wrap(err)
+	}
+	info := fmt.Sprintf("pid=%d host=%s date=%s command=%s\n",
+		os.Getpid(), host, time.Now().Format(time.RFC3339), strings.Join(cmdArgs, " "))
+
+	if err := file.Truncate(0); err != nil {
+		return erro.Wrap(err)
+	} else if _, err := file.WriteAt([]byte(info), 0); err != nil {
+		return erro.Wrap(err)
+	}
+	return nil
+}
+
+func clearOwner(file *os.File) {
+	file.Truncate(0)
+}
+
+// 端末から送られたと考えられるシグナルかどうか。
+// 端末が送る SIGINT, SIGHUP で、golock が端末のフォアグラウンドプロセスグループにいる場合。
+// 送り主は分からないので、kill -INT 等で golock だけに送られても、フォアグラウンドにいれば端末からとみなす。
+func fromTerminal(sig os.Signal) bool {
+	if sig != syscall.SIGINT && sig != syscall.SIGHUP {
+		return false
+	}
+	return isForeground()
+}
+
+// 制御端末のフォアグラウンドプロセスグループにいるかどうか。
+// 制御端末が無ければ false。
+func isForeground() bool {
+	tty, err := os.Open("/dev/tty")
+	if err != nil {
+		return false
+	}
+	defer tty.Close()
+	var pgrp int32
+	if _, _, errno := syscall.Syscall(syscall.SYS_IOCTL, tty.Fd(), syscall.TIOCGPGRP, uintptr(unsafe.Pointer(&pgrp))); errno != 0 {
+		return false
+	}
+	return int(pgrp) == syscall.Getpgrp()
+}
+
+// コマンドを実行して、終了コードを返す。
+func execute(cmd *exec.Cmd, stderr io.Writer) int {
+	if err := cmd.Start(); err != nil {
+		fmt.Fprintln(stderr, erro.Wrap(err))
+		return errCode
+	}
+
+	// golock だけに送られたシグナルも子プロセスに届ける。
+	// 端末からのシグナルは同じプロセスグループの子プロセスにも直接届くので、2 回送らないように除く。
+	// 子プロセスを別のプロセスグループにすると、端末から読めなくなるのでそうはしない。
+	sigCh := make(chan os.Signal, 1)
+	signal.Notify(sigCh, syscall.SIGHUP, syscall.SIGINT, syscall.SIGTERM)
+	defer signal.Stop(sigCh)
+	doneCh := make(chan struct{})
+	defer close(doneCh)
+	go func() {
+		for {
+			select {
+			case sig := <-sigCh:
+				if !fromTerminal(sig) {
+					cmd.Process.Signal(sig)
+				}
+			case <-doneCh:
+				return
+			}
+		}
+	}()
+
+	err := cmd.Wait()
+	if err == nil {
+		return 0
+	}
+	exitErr, ok := err.(*exec.ExitError)
+	if !ok {
+		fmt.Fprintln(stderr, erro.Wrap(err))
+		return errCode
+	}
+	if status, ok := exitErr.Sys().(syscall.WaitStatus); ok && status.Signaled() {
+		// シェルの流儀。
+		return 128 + int(status.Signal())
+	}
+	return exitErr.ExitCode()
+}
@@ -0,0 +1,139 @@
+// Copyright 2015 realglobe, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"bytes"
+	"io/ioutil"
+	"os"
+	"strings"
+	"syscall"
+	"testing"
+
+	"github.com/realglobe-Inc/go-lib/lock"
+)
+
+func testLockPath(t *testing.T) string {
+	file, err := ioutil.TempFile("", "test_golock")
+	if err != nil {
+		t.Fatal(err)
+	}
+	path := file.Name()
+	if err := file.Close(); err != nil {
+		t.Fatal(err)
+	} else if err := os.Remove(path); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestRunExitCode(t *testing.T) {
+	path := testLockPath(t)
+	defer os.Remove(path)
+
+	var stderr bytes.Buffer
+	if code := run([]string{path, "sh", "-c", "exit 3"}, nil, ioutil.Discard, &stderr); code != 3 {
+		t.Fatal(code, stderr.String())
+	}
+}
+
+func TestRunUsage(t *testing.T) {
+	if code := run([]string{"only-path"}, nil, ioutil.Discard, ioutil.Discard); code != usageCode {
+		t.Fatal(code)
+	} else if code := run([]string{"-s", "-owner", "path", "true"}, nil, ioutil.Discard, ioutil.Discard); code != usageCode {
+		t.Fatal(code)
+	} else if code := run([]string{"-n", "-w", "10ms", "path", "true"}, nil, ioutil.Discard, ioutil.Discard); code != usageCode {
+		t.Fatal(code)
+	}
+}
+
+// 端末の無いところから送られたシグナルは子プロセスに届ける。
+func TestFromTerminal(t *testing.T) {
+	if fromTerminal(syscall.SIGTERM) {
+		t.Error("SIGTERM is from terminal")
+	}
+	if !isForeground() && fromTerminal(syscall.SIGINT) {
+		t.Error("SIGINT is from terminal without foreground")
+	}
+}
+
+func TestRunConflict(t *testing.T) {
+	path := testLockPath(t)
+	defer os.Remove(path)
+
+	locker, err := lock.Lock(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer locker.Unlock()
+
+	if code := run([]string{"-n", path, "true"}, nil, ioutil.Discard, ioutil.Discard); code != defaultConflictCode {
+		t.Fatal(code)
+	} else if code := run([]string{"-n", "-E", "75", path, "true"}, nil, ioutil.Discard, ioutil.Discard); code != 75 {
+		t.Fatal(code)
+	} else if code := run([]string{"-w", "10ms", "-s", path, "true"}, nil, ioutil.Discard, ioutil.Discard); code != defaultConflictCode {
+		t.Fatal(code)
+	}
+}
+
+func TestRunShared(t *testing.T) {
+	path := testLockPath(t)
+	defer os.Remove(path)
+
+	locker, err := lock.LockShared(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer locker.Unlock()
+
+	if code := run([]string{"-n", "-s", path, "true"}, nil, ioutil.Discard, ioutil.Discard); code != 0 {
+		t.Fatal(code)
+	}
+}
+
+func TestRunPassFd(t *testing.T) {
+	path := testLockPath(t)
+	defer os.Remove(path)
+
+	script := `test "$` + fdEnv + `" = 3 && test -e /proc/self/fd/3`
+	if code := run([]string{"-pass-fd", path, "sh", "-c", script}, nil, ioutil.Discard, ioutil.Discard); code != 0 {
+		t.Fatal(code)
+	}
+
+	// 頼まなければ渡さない。
+	script = `test -z "$` + fdEnv + `" && test ! -e /proc/self/fd/3`
+	if code := run([]string{path, "sh", "-c", script}, nil, ioutil.Discard, ioutil.Discard); code != 0 {
+		t.Fatal(code)
+	}
+}
+
+func TestRunOwner(t *testing.T) {
+	path := testLockPath(t)
+	defer os.Remove(path)
+
+	var stdout bytes.Buffer
+	if code := run([]string{"-owner", path, "cat", path}, nil, &stdout, ioutil.Discard); code != 0 {
+		t.Fatal(code)
+	} else if !strings.HasPrefix(stdout.String(), "pid=") {
+		t.Fatal(stdout.String())
+	}
+
+	// 解放後は消えている。
+	if buff, err := ioutil.ReadFile(path); err != nil {
+		t.Fatal(err)
+	} else if len(buff) > 0 {
+		t.Fatal(string(buff))
+	}
+}
@@ -0,0 +1,194 @@
+// Copyright 2015 realglobe, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// rglog.ServeAdmin で提供されているロガーの設定を見たり変えたりする。
+//
+//	rglogctl -s <socket> list
+//	rglogctl -s <socket> set [-ttl <duration>] <logger> <level>
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/realglobe-Inc/go-lib/erro"
+	"github.com/realglobe-Inc/go-lib/rglog/admin"
+	"github.com/realglobe-Inc/go-lib/rglog/level"
+)
+
+const (
+	// 引数がおかしいとき。sysexits.h の EX_USAGE。
+	usageCode = 64
+	// 通信できなかったとき。sysexits.h の EX_UNAVAILABLE。
+	unavailableCode = 69
+	// 要求が受け付けられなかったとき。sysexits.h の EX_DATAERR。
+	rejectedCode = 65
+)
+
+// ソケット越しでも URL は要る。ホスト名は使われない。
+const baseUrl = "http://rglog"
+
+func main() {
+	os.Exit(run(os.Args[1:], os.Stdout, os.Stderr))
+}
+
+// 終了コードを返す。
+func run(args []string, stdout, stderr io.Writer) int {
+	flags := flag.NewFlagSet("rglogctl", flag.ContinueOnError)
+	flags.SetOutput(stderr)
+	flags.Usage = func() {
+		fmt.Fprintln(stderr, "Usage: rglogctl -s <socket> list")
+		fmt.Fprintln(stderr, "       rglogctl -s <socket> set [-ttl <duration>] <logger> <level>")
+		flags.PrintDefaults()
+	}
+	sock := flags.String("s", "", "Unix domain socket served by rglog.ServeAdmin")
+	if err := flags.Parse(args); err != nil {
+		return usageCode
+	} else if *sock == "" || flags.NArg() < 1 {
+		flags.Usage()
+		return usageCode
+	}
+	client := newClient(*sock)
+
+	switch flags.Arg(0) {
+	case "list":
+		if flags.NArg() != 1 {
+			flags.Usage()
+			return usageCode
+		}
+		return list(client, stdout, stderr)
+	case "set":
+		setFlags := flag.NewFlagSet("rglogctl set", flag.ContinueOnError)
+		setFlags.SetOutput(stderr)
+		setFlags.Usage = flags.Usage
+		ttl := setFlags.Duration("ttl", 0, "Revert to the previous level after this duration (0 means never)")
+		if err := setFlags.Parse(flags.Args()[1:]); err != nil {
+			return usageCode
+		} else if setFlags.NArg() != 2 {
+			flags.Usage()
+			return usageCode
+		} else if _, err := level.ValueOf(setFlags.Arg(1)); err != nil {
+			fmt.Fprintln(stderr, "invalid level", setFlags.Arg(1))
+			return usageCode
+		}
+		return set(client, setFlags.Arg(0), setFlags.Arg(1), *ttl, stdout, stderr)
+	default:
+		flags.Usage()
+		return usageCode
+	}
+}
+
+func newClient(sock string) *http.Client {
+	return &http.Client{
+		Transport: &http.Transport{
+			DialContext: func(ctx context.Context, _, _ string) (net.Conn, error) {
+				var dialer net.Dialer
+				return dialer.DialContext(ctx, "unix", sock)
+			},
+		},
+		Timeout: 10 * time.Second,
+	}
+}
+
+func list(client *http.Client, stdout, stderr io.Writer) int {
+	var infos []*admin.LoggerInfo
+	if code := request(client, "GET", "/loggers", nil, &infos, stderr); code != 0 {
+		return code
+	}
+
+	w := tabwriter.NewWriter(stdout, 0, 8, 2, ' ', 0)
+	fmt.Fprintln(w, "NAME\tLEVEL\tUSEPARENT\tHANDLERS\tREVERT")
+	for _, info := range infos {
+		printInfo(w, info)
+	}
+	w.Flush()
+	return 0
+}
+
+func set(client *http.Client, name, lv string, ttl time.Duration, stdout, stderr io.Writer) int {
+	form := url.Values{"name": {name}, "level": {lv}}
+	if ttl > 0 {
+		form.Set("ttl", ttl.String())
+	}
+	var info admin.LoggerInfo
+	if code := request(client, "POST", "/level", form, &info, stderr); code != 0 {
+		return code
+	}
+
+	w := tabwriter.NewWriter(stdout, 0, 8, 2, ' ', 0)
+	fmt.Fprintln(w, "NAME\tLEVEL\tUSEPARENT\tHANDLERS\tREVERT")
+	printInfo(w, &info)
+	w.Flush()
+	return 0
+}
+
+func printInfo(w io.Writer, info *admin.LoggerInfo) {
+	name := info.Name
+	if name == "" {
+		name = `""`
+	}
+	hndls := []string{}
+	for _, hndl := range info.Handlers {
+		hndls = append(hndls, hndl.Key+":"+hndl.Level.String())
+	}
+	hndlsStr := "-"
+	if len(hndls) > 0 {
+		hndlsStr = strings.Join(hndls, ",")
+	}
+	rev := "-"
+	if info.Revert != nil {
+		rev = info.Revert.Level.String() + "@" + info.Revert.At.Format(time.RFC3339)
+	}
+	fmt.Fprintf(w, "%s\t%s\t%t\t%s\t%s\n", name, info.Level, info.UseParent, hndlsStr, rev)
+}
+
+// 成功したら 0 を返す。
+func request(client *http.Client, method, path string, form url.Values, v interface{}, stderr io.Writer) int {
+	var resp *http.Response
+	var err error
+	if method == "POST" {
+		resp, err = client.PostForm(baseUrl+path, form)
+	} else {
+		resp, err = client.Get(baseUrl + path)
+	}
+	if err != nil {
+		fmt.Fprintln(stderr, erro.Unwrap(err))
+		return unavailableCode
+	}
+	defer resp.Body.Close()
+
+	body, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		fmt.Fprintln(stderr, err)
+		return unavailableCode
+	} else if resp.StatusCode != http.StatusOK {
+		fmt.Fprintln(stderr, strings.TrimSpace(string(body)))
+		return rejectedCode
+	} else if err := json.Unmarshal(body, v); err != nil {
+		fmt.Fprintln(stderr, err)
+		return unavailableCode
+	}
+	return 0
+}
@@ -0,0 +1,103 @@
+// Copyright 2015 realglobe, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"bytes"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/realglobe-Inc/go-lib/rglog/admin"
+	"github.com/realglobe-Inc/go-lib/rglog/handler"
+	"github.com/realglobe-Inc/go-lib/rglog/level"
+	"github.com/realglobe-Inc/go-lib/rglog/logger"
+)
+
+func testServe(t *testing.T, mgr logger.Manager) (sock string, stop func()) {
+	dir, err := ioutil.TempDir("", "test_rglogctl")
+	if err != nil {
+		t.Fatal(err)
+	}
+	sock = filepath.Join(dir, "admin.sock")
+	closer, err := admin.ServeUnix(sock, admin.NewHandler(mgr))
+	if err != nil {
+		os.RemoveAll(dir)
+		t.Fatal(err)
+	}
+	return sock, func() {
+		closer.Close()
+		os.RemoveAll(dir)
+	}
+}
+
+func TestRunList(t *testing.T) {
+	mgr := logger.NewLockLoggerManager()
+	mgr.Logger("").AddHandler("console", handler.NewNopHandler())
+	mgr.Logger("a/b").SetLevel(level.DEBUG)
+	sock, stop := testServe(t, mgr)
+	defer stop()
+
+	var stdout, stderr bytes.Buffer
+	if code := run([]string{"-s", sock, "list"}, &stdout, &stderr); code != 0 {
+		t.Fatal(code, stderr.String())
+	}
+	lines := strings.Split(strings.TrimSpace(stdout.String()), "\n")
+	if len(lines) != 3 {
+		t.Fatal(stdout.String())
+	} else if fields := strings.Fields(lines[1]); fields[0] != `""` || fields[3] != "console:ALL" {
+		t.Fatal(lines[1])
+	} else if fields := strings.Fields(lines[2]); fields[0] != "a/b" || fields[1] != "DEBUG" {
+		t.Fatal(lines[2])
+	}
+}
+
+func TestRunSet(t *testing.T) {
+	mgr := logger.NewLockLoggerManager()
+	mgr.Logger("a").SetLevel(level.INFO)
+	sock, stop := testServe(t, mgr)
+	defer stop()
+
+	var stdout, stderr bytes.Buffer
+	if code := run([]string{"-s", sock, "set", "-ttl", "10m", "a", "debug"}, &stdout, &stderr); code != 0 {
+		t.Fatal(code, stderr.String())
+	} else if lv := mgr.Logger("a").Level(); lv != level.DEBUG {
+		t.Fatal(lv)
+	} else if !strings.Contains(stdout.String(), "INFO@") {
+		t.Fatal(stdout.String())
+	}
+}
+
+func TestRunUsage(t *testing.T) {
+	for _, args := range [][]string{
+		{"list"},
+		{"-s", "sock"},
+		{"-s", "sock", "unknown"},
+		{"-s", "sock", "set", "a"},
+		{"-s", "sock", "set", "a", "DEBG"},
+	} {
+		if code := run(args, ioutil.Discard, ioutil.Discard); code != usageCode {
+			t.Error(args, code)
+		}
+	}
+}
+
+func TestRunUnavailable(t *testing.T) {
+	if code := run([]string{"-s", filepath.Join(os.TempDir(), "test_rglogctl_none.sock"), "list"}, ioutil.Discard, ioutil.Discard); code != unavailableCode {
+		t.Fatal(code)
+	}
+}
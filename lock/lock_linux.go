@@ -26,23 +26,38 @@ import (
 
 type Locker os.File
 
+// ロックファイルのパーミッション。
+const filePerm os.FileMode = 0666
+
 // ロックするまで待つ。
 func Lock(path string) (*Locker, error) {
-	return lock(path, 0)
+	return lock(path, syscall.LOCK_EX)
 }
 
 // ロックできなかったら nil を返す。
 func TryLock(path string) (*Locker, error) {
-	return lock(path, syscall.LOCK_NB)
+	return lock(path, syscall.LOCK_EX|syscall.LOCK_NB)
+}
+
+// 共有ロックするまで待つ。
+// 共有ロック同士は同時に取れるが、Lock 等による排他ロックとは同時に取れない。
+func LockShared(path string) (*Locker, error) {
+	return lock(path, syscall.LOCK_SH)
 }
 
-func lock(path string, flag int) (*Locker, error) {
-	file, err := os.Create(path)
+// 共有ロックできなかったら nil を返す。
+func TryLockShared(path string) (*Locker, error) {
+	return lock(path, syscall.LOCK_SH|syscall.LOCK_NB)
+}
+
+func lock(path string, how int) (*Locker, error) {
+	// 所有者情報等が書き込まれているかもしれないので、切り詰めない。
+	file, err := os.OpenFile(path, os.O_RDWR|os.O_CREATE, filePerm)
 	if err != nil {
 		return nil, erro.Wrap(err)
 	}
 
-	if err := syscall.Flock(int(file.Fd()), syscall.LOCK_EX|flag); err != nil {
+	if err := syscall.Flock(int(file.Fd()), how); err != nil {
 		file.Close()
 
 		if err == syscall.EWOULDBLOCK {
@@ -56,6 +71,13 @@ func lock(path string, flag int) (*Locker, error) {
 	return (*Locker)(file), nil
 }
 
+// ロックファイルを返す。
+// 子プロセスに渡したり、所有者情報を書き込んだりする用。
+// Close してはいけない。
+func (lock *Locker) File() *os.File {
+	return (*os.File)(lock)
+}
+
 // 解放する。
 func (lock *Locker) Unlock() error {
 	file := (*os.File)(lock)
@@ -72,6 +94,16 @@ func (lock *Locker) Unlock() error {
 // ロックできるか指定した時間が経つまで待つ。
 // ロックできずに指定した時間が経ったら nil を返す。
 func WaitLock(path string, wait time.Duration) (*Locker, error) {
+	return waitLock(path, wait, Lock)
+}
+
+// 共有ロックできるか指定した時間が経つまで待つ。
+// 共有ロックできずに指定した時間が経ったら nil を返す。
+func WaitLockShared(path string, wait time.Duration) (*Locker, error) {
+	return waitLock(path, wait, LockShared)
+}
+
+func waitLock(path string, wait time.Duration, lockFunc func(string) (*Locker, error)) (*Locker, error) {
 
 	timer := time.NewTimer(wait)
 	defer timer.Stop()
@@ -79,7 +111,7 @@ func WaitLock(path string, wait time.Duration) (*Locker, error) {
 	errCh := make(chan error, 1)
 	ackCh := make(chan bool, 1)
 	go func() {
-		locker, err := Lock(path)
+		locker, err := lockFunc(path)
 		if err != nil {
 			errCh <- err
 			return
@@ -365,3 +365,108 @@ func TestTimeout(t *testing.T) {
 		t.Fatal(dur)
 	}
 }
+
+func TestShared(t *testing.T) {
+	file, err := ioutil.TempFile("", "test_lock")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.Remove(file.Name())
+	path := file.Name()
+
+	if err := file.Close(); err != nil {
+		t.Fatal(err)
+	} else if err := os.Remove(path); err != nil {
+		t.Fatal(err)
+	}
+
+	lock1, err := LockShared(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer lock1.Unlock()
+
+	// 共有ロック同士は取れる。
+	lock2, err := TryLockShared(path)
+	if err != nil {
+		t.Fatal(err)
+	} else if lock2 == nil {
+		t.Fatal("shared lock failed")
+	}
+	defer lock2.Unlock()
+
+	lock3, err := WaitLockShared(path, time.Millisecond)
+	if err != nil {
+		t.Fatal(err)
+	} else if lock3 == nil {
+		t.Fatal("shared lock failed")
+	}
+	lock3.Unlock()
+
+	// 排他ロックは取れない。
+	lock, err := TryLock(path)
+	if err != nil {
+		t.Fatal(err)
+	} else if lock != nil {
+		lock.Unlock()
+		t.Fatal(lock)
+	}
+}
+
+func TestExclusiveBlocksShared(t *testing.T) {
+	file, err := ioutil.TempFile("", "test_lock")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.Remove(file.Name())
+	path := file.Name()
+
+	if err := file.Close(); err != nil {
+		t.Fatal(err)
+	} else if err := os.Remove(path); err != nil {
+		t.Fatal(err)
+	}
+
+	lock, err := Lock(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer lock.Unlock()
+
+	lock2, err := TryLockShared(path)
+	if err != nil {
+		t.Fatal(err)
+	} else if lock2 != nil {
+		lock2.Unlock()
+		t.Fatal(lock2)
+	}
+}
+
+// ロックを取っても中身は消えない。
+func TestKeepContent(t *testing.T) {
+	file, err := ioutil.TempFile("", "test_lock")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.Remove(file.Name())
+	path := file.Name()
+
+	content := "owner"
+	if _, err := file.WriteString(content); err != nil {
+		t.Fatal(err)
+	} else if err := file.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	lock, err := Lock(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer lock.Unlock()
+
+	if buff, err := ioutil.ReadFile(path); err != nil {
+		t.Fatal(err)
+	} else if string(buff) != content {
+		t.Fatal(string(buff), content)
+	}
+}
@@ -0,0 +1,255 @@
+// Copyright 2015 realglobe, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// ロガーの設定を実行中に見たり変えたりするための HTTP インターフェース。
+//
+//	GET /loggers
+//	    全ロガーの重要度、UseParent、ハンドラを JSON で返す。
+//	POST /level name={ロガー名}&level={重要度}[&ttl={時間}]
+//	    既にあるロガーの重要度を変える。無いロガーなら 404 を返す。
+//	    ttl を指定すると、その時間が経ったら元に戻す。
+//	    ただし、その間に設定ファイルの再読み込み等で重要度が変わっていたら戻さない。
+package admin
+
+import (
+	"encoding/json"
+	"io"
+	"net"
+	"net/http"
+	"os"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/realglobe-Inc/go-lib/erro"
+	"github.com/realglobe-Inc/go-lib/rglog/level"
+	"github.com/realglobe-Inc/go-lib/rglog/logger"
+)
+
+// ロガーの状態。
+type LoggerInfo struct {
+	Name      string        `json:"name"`
+	Level     level.Level   `json:"level"`
+	UseParent bool          `json:"useParent"`
+	Handlers  []HandlerInfo `json:"handlers"`
+	// 重要度が自動で戻される予定。
+	Revert *RevertInfo `json:"revert,omitempty"`
+}
+
+// ハンドラの状態。
+type HandlerInfo struct {
+	Key   string      `json:"key"`
+	Level level.Level `json:"level"`
+}
+
+// 重要度を戻す予定。
+type RevertInfo struct {
+	Level level.Level `json:"level"`
+	At    time.Time   `json:"at"`
+}
+
+type adminHandler struct {
+	mgr *revertManager
+	mux *http.ServeMux
+}
+
+// mgr のロガーを扱う http.Handler をつくる。
+func NewHandler(mgr logger.Manager) http.Handler {
+	hndl := &adminHandler{
+		mgr: &revertManager{Manager: mgr, reverts: map[string]*revert{}},
+		mux: http.NewServeMux(),
+	}
+	hndl.mux.HandleFunc("/loggers", hndl.serveLoggers)
+	hndl.mux.HandleFunc("/level", hndl.serveLevel)
+	return hndl
+}
+
+func (hndl *adminHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	hndl.mux.ServeHTTP(w, r)
+}
+
+func (hndl *adminHandler) serveLoggers(w http.ResponseWriter, r *http.Request) {
+	if r.Method != "GET" {
+		w.Header().Set("Allow", "GET")
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	infos := []*LoggerInfo{}
+	for _, name := range hndl.mgr.Names() {
+		infos = append(infos, hndl.mgr.info(name))
+	}
+	writeJson(w, infos)
+}
+
+func (hndl *adminHandler) serveLevel(w http.ResponseWriter, r *http.Request) {
+	if r.Method != "POST" {
+		w.Header().Set("Allow", "POST")
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	if err := r.ParseForm(); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if _, ok := r.PostForm["name"]; !ok {
+		http.Error(w, "no name", http.StatusBadRequest)
+		return
+	}
+	name := r.PostForm.Get("name")
+	if !hndl.mgr.exists(name) {
+		// 打ち間違いでロガーが増えないように。
+		http.Error(w, "no logger "+strconv.Quote(name), http.StatusNotFound)
+		return
+	}
+	lv, err := level.ValueOf(r.PostForm.Get("level"))
+	if err != nil {
+		http.Error(w, "invalid level "+r.PostForm.Get("level"), http.StatusBadRequest)
+		return
+	}
+	var ttl time.Duration
+	if s := r.PostForm.Get("ttl"); s != "" {
+		ttl, err = time.ParseDuration(s)
+		if err != nil || ttl <= 0 {
+			http.Error(w, "invalid ttl "+s, http.StatusBadRequest)
+			return
+		}
+	}
+
+	hndl.mgr.setLevel(name, lv, ttl)
+	writeJson(w, hndl.mgr.info(name))
+}
+
+func writeJson(w http.ResponseWriter, v interface{}) {
+	data, err := json.Marshal(v)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.Write(data)
+}
+
+// 重要度を自動で戻すために、変える前の重要度を覚えておく。
+type revertManager struct {
+	logger.Manager
+
+	lock    sync.Mutex
+	reverts map[string]*revert
+}
+
+type revert struct {
+	// 戻す重要度。
+	lv level.Level
+	// 変えた重要度。これから変わっていたら戻さない。
+	setLv level.Level
+	at    time.Time
+	timer *time.Timer
+}
+
+func (mgr *revertManager) exists(name string) bool {
+	for _, name2 := range mgr.Names() {
+		if name2 == name {
+			return true
+		}
+	}
+	return false
+}
+
+// ttl が 0 なら戻さない。
+func (mgr *revertManager) setLevel(name string, lv level.Level, ttl time.Duration) {
+	mgr.lock.Lock()
+	defer mgr.lock.Unlock()
+
+	log := mgr.Logger(name)
+	old := mgr.reverts[name]
+	if old != nil {
+		old.timer.Stop()
+		delete(mgr.reverts, name)
+	}
+	if ttl > 0 {
+		// 続けて変えられたら、最初に変える前の重要度に戻す。
+		origLv := log.Level()
+		if old != nil && origLv == old.setLv {
+			origLv = old.lv
+		}
+		rev := &revert{lv: origLv, setLv: lv, at: time.Now().Add(ttl)}
+		rev.timer = time.AfterFunc(ttl, func() {
+			mgr.lock.Lock()
+			defer mgr.lock.Unlock()
+
+			if mgr.reverts[name] != rev {
+				return
+			}
+			delete(mgr.reverts, name)
+			if log.Level() != rev.setLv {
+				// 他で変えられた。
+				return
+			}
+			log.SetLevel(rev.lv)
+		})
+		mgr.reverts[name] = rev
+	}
+	log.SetLevel(lv)
+}
+
+func (mgr *revertManager) info(name string) *LoggerInfo {
+	log := mgr.Logger(name)
+	info := &LoggerInfo{
+		Name:      name,
+		Level:     log.Level(),
+		UseParent: log.UseParent(),
+		Handlers:  []HandlerInfo{},
+	}
+	hndls := log.Handlers()
+	keys := []string{}
+	for key := range hndls {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	for _, key := range keys {
+		info.Handlers = append(info.Handlers, HandlerInfo{key, hndls[key].Level()})
+	}
+
+	mgr.lock.Lock()
+	defer mgr.lock.Unlock()
+	if rev := mgr.reverts[name]; rev != nil {
+		info.Revert = &RevertInfo{rev.lv, rev.at}
+	}
+	return info
+}
+
+// Unix ドメインソケット path で hndl を提供する。
+// path に古いソケットが残っていたら消す。
+// 認証しないので、ソケットは所有者しか読み書きできないようにする。
+// 返り値を Close すると止まる。
+func ServeUnix(path string, hndl http.Handler) (io.Closer, error) {
+	if fi, err := os.Lstat(path); err == nil && fi.Mode()&os.ModeSocket != 0 {
+		if err := os.Remove(path); err != nil {
+			return nil, erro.Wrap(err)
+		}
+	}
+	lis, err := net.Listen("unix", path)
+	if err != nil {
+		return nil, erro.Wrap(err)
+	}
+	if err := os.Chmod(path, 0600); err != nil {
+		lis.Close()
+		return nil, erro.Wrap(err)
+	}
+	go http.Serve(lis, hndl)
+	return lis, nil
+}
@@ -0,0 +1,201 @@
+// Copyright 2015 realglobe, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package admin
+
+import (
+	"context"
+	"encoding/json"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/realglobe-Inc/go-lib/rglog/handler"
+	"github.com/realglobe-Inc/go-lib/rglog/level"
+	"github.com/realglobe-Inc/go-lib/rglog/logger"
+)
+
+func postLevel(hndl http.Handler, form url.Values) *httptest.ResponseRecorder {
+	r := httptest.NewRequest("POST", "/level", strings.NewReader(form.Encode()))
+	r.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	w := httptest.NewRecorder()
+	hndl.ServeHTTP(w, r)
+	return w
+}
+
+func TestLoggers(t *testing.T) {
+	mgr := logger.NewLockLoggerManager()
+	mgr.Logger("").SetLevel(level.INFO)
+	log := mgr.Logger("a/b")
+	log.SetLevel(level.DEBUG)
+	log.SetUseParent(false)
+	hndl := handler.NewNopHandler()
+	hndl.SetLevel(level.WARN)
+	log.AddHandler("test", hndl)
+
+	w := httptest.NewRecorder()
+	NewHandler(mgr).ServeHTTP(w, httptest.NewRequest("GET", "/loggers", nil))
+	if w.Code != http.StatusOK {
+		t.Fatal(w.Code, w.Body.String())
+	}
+	var infos []*LoggerInfo
+	if err := json.Unmarshal(w.Body.Bytes(), &infos); err != nil {
+		t.Fatal(err)
+	} else if len(infos) != 2 {
+		t.Fatal(infos)
+	} else if infos[0].Name != "" || infos[0].Level != level.INFO || !infos[0].UseParent || len(infos[0].Handlers) != 0 {
+		t.Fatal(infos[0])
+	} else if infos[1].Name != "a/b" || infos[1].Level != level.DEBUG || infos[1].UseParent || len(infos[1].Handlers) != 1 {
+		t.Fatal(infos[1])
+	} else if h := infos[1].Handlers[0]; h.Key != "test" || h.Level != level.WARN {
+		t.Fatal(h)
+	}
+}
+
+func TestLevel(t *testing.T) {
+	mgr := logger.NewLockLoggerManager()
+	mgr.Logger("a").SetLevel(level.INFO)
+	hndl := NewHandler(mgr)
+
+	if w := postLevel(hndl, url.Values{"name": {"a"}, "level": {"debug"}}); w.Code != http.StatusOK {
+		t.Fatal(w.Code, w.Body.String())
+	} else if lv := mgr.Logger("a").Level(); lv != level.DEBUG {
+		t.Fatal(lv)
+	}
+
+	// 無いロガーはつくらない。
+	if w := postLevel(hndl, url.Values{"name": {"x"}, "level": {"debug"}}); w.Code != http.StatusNotFound {
+		t.Fatal(w.Code, w.Body.String())
+	} else if names := mgr.Names(); len(names) != 1 || names[0] != "a" {
+		t.Fatal(names)
+	}
+
+	for _, form := range []url.Values{
+		{"level": {"DEBUG"}},
+		{"name": {"a"}, "level": {"DEBG"}},
+		{"name": {"a"}, "level": {"DEBUG"}, "ttl": {"-1s"}},
+	} {
+		if w := postLevel(hndl, form); w.Code != http.StatusBadRequest {
+			t.Error(form, w.Code, w.Body.String())
+		}
+	}
+
+	w := httptest.NewRecorder()
+	hndl.ServeHTTP(w, httptest.NewRequest("GET", "/level", nil))
+	if w.Code != http.StatusMethodNotAllowed {
+		t.Fatal(w.Code)
+	}
+}
+
+func TestLevelTtl(t *testing.T) {
+	mgr := logger.NewLockLoggerManager()
+	mgr.Logger("a").SetLevel(level.INFO)
+	hndl := NewHandler(mgr)
+
+	w := postLevel(hndl, url.Values{"name": {"a"}, "level": {"DEBUG"}, "ttl": {"50ms"}})
+	if w.Code != http.StatusOK {
+		t.Fatal(w.Code, w.Body.String())
+	}
+	var info LoggerInfo
+	if err := json.Unmarshal(w.Body.Bytes(), &info); err != nil {
+		t.Fatal(err)
+	} else if info.Level != level.DEBUG || info.Revert == nil || info.Revert.Level != level.INFO {
+		t.Fatal(info)
+	}
+
+	// 続けて変えても、最初の重要度に戻る。
+	if w := postLevel(hndl, url.Values{"name": {"a"}, "level": {"TRACE"}, "ttl": {"50ms"}}); w.Code != http.StatusOK {
+		t.Fatal(w.Code, w.Body.String())
+	} else if lv := mgr.Logger("a").Level(); lv != level.TRACE {
+		t.Fatal(lv)
+	}
+
+	for i := 0; mgr.Logger("a").Level() != level.INFO; i++ {
+		if i > 100 {
+			t.Fatal(mgr.Logger("a").Level())
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+func TestLevelTtlCancel(t *testing.T) {
+	mgr := logger.NewLockLoggerManager()
+	mgr.Logger("a").SetLevel(level.INFO)
+	hndl := NewHandler(mgr)
+
+	postLevel(hndl, url.Values{"name": {"a"}, "level": {"DEBUG"}, "ttl": {"20ms"}})
+	// ttl 無しで変えたら戻さない。
+	postLevel(hndl, url.Values{"name": {"a"}, "level": {"WARN"}})
+	time.Sleep(50 * time.Millisecond)
+	if lv := mgr.Logger("a").Level(); lv != level.WARN {
+		t.Fatal(lv)
+	}
+}
+
+// 戻す前に他で重要度が変えられたら戻さない。
+func TestLevelTtlChanged(t *testing.T) {
+	mgr := logger.NewLockLoggerManager()
+	mgr.Logger("a").SetLevel(level.INFO)
+	hndl := NewHandler(mgr)
+
+	postLevel(hndl, url.Values{"name": {"a"}, "level": {"DEBUG"}, "ttl": {"20ms"}})
+	// 設定ファイルの再読み込みとか。
+	mgr.Logger("a").SetLevel(level.WARN)
+	time.Sleep(50 * time.Millisecond)
+	if lv := mgr.Logger("a").Level(); lv != level.WARN {
+		t.Fatal(lv)
+	}
+}
+
+func TestServeUnix(t *testing.T) {
+	dir, err := ioutil.TempDir("", "rglog")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "admin.sock")
+
+	closer, err := ServeUnix(path, NewHandler(logger.NewLockLoggerManager()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer closer.Close()
+
+	if fi, err := os.Stat(path); err != nil {
+		t.Fatal(err)
+	} else if perm := fi.Mode().Perm(); perm != 0600 {
+		t.Fatal(perm)
+	}
+
+	client := &http.Client{Transport: &http.Transport{
+		DialContext: func(ctx context.Context, network, addr string) (net.Conn, error) {
+			return net.Dial("unix", path)
+		},
+	}}
+	resp, err := client.Get("http://admin/loggers")
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		t.Fatal(resp.StatusCode)
+	}
+}
@@ -0,0 +1,770 @@
+// Copyright 2015 realglobe, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package rglog
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"regexp"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/realglobe-Inc/go-lib/erro"
+	"github.com/realglobe-Inc/go-lib/rglog/handler"
+	"github.com/realglobe-Inc/go-lib/rglog/level"
+	"github.com/realglobe-Inc/go-lib/rglog/logger"
+)
+
+// 設定ファイルでロガーとハンドラを設定する。
+//
+//	{
+//	  "loggers": {
+//	    "": {"level": "INFO", "useParent": false, "handlers": ["console"]},
+//	    "a/b/c": {"level": "DEBUG", "handlers": ["file"]}
+//	  },
+//	  "handlers": {
+//	    "console": {"type": "console", "level": "INFO"},
+//	    "file": {"type": "rotate", "path": "/var/log/a.log", "limit": 10485760, "num": 10, "formatter": "detail"}
+//	  }
+//	}
+//
+// ロガーの handlers を指定すると、ロガーのハンドラはそれらに置き換わる。
+// ハンドラはハンドラ名をキーとしてロガーに登録される。
+// 設定に無いロガー、項目はそのまま。
+//
+// YAML 等で書くなら ParseConfigUsing を使う。
+type Config struct {
+	Loggers  map[string]*LoggerConfig `json:"loggers" yaml:"loggers"`
+	Handlers map[string]HandlerConfig `json:"handlers" yaml:"handlers"`
+}
+
+// ロガーの設定。
+type LoggerConfig struct {
+	Level     *level.Level `json:"level" yaml:"level"`
+	UseParent *bool        `json:"useParent" yaml:"useParent"`
+	// ハンドラ名。
+	Handlers []string `json:"handlers" yaml:"handlers"`
+}
+
+// ハンドラの設定。
+// "type" でハンドラの種類、"level" で重要度を指定する。
+// それ以外はハンドラの種類ごとの項目。
+type HandlerConfig map[string]interface{}
+
+// JSON の設定ファイルを読んで、設定する。
+// 2 回目以降は前回との差分を反映させる。
+func Configure(r io.Reader) error {
+	conf, err := ParseConfig(r)
+	if err != nil {
+		return erro.Wrap(err)
+	}
+	return current().conftor.Apply(conf)
+}
+
+// Configure の YAML 等の版。
+// unmarshal は ParseConfigUsing と同じ。
+func ConfigureUsing(r io.Reader, unmarshal func(data []byte, v interface{}) error) error {
+	conf, err := ParseConfigUsing(r, unmarshal)
+	if err != nil {
+		return erro.Wrap(err)
+	}
+	return current().conftor.Apply(conf)
+}
+
+// JSON の設定ファイルを読む。
+func ParseConfig(r io.Reader) (*Config, error) {
+	data, err := ioutil.ReadAll(r)
+	if err != nil {
+		return nil, erro.Wrap(err)
+	}
+	return parseConfig(data)
+}
+
+// YAML 等の設定ファイルを読む。
+// unmarshal は yaml.Unmarshal のように、data を v に読み込む関数。
+// 一旦 interface{} に読み込んでから JSON と同じように検査するので、書ける項目も JSON と同じ。
+//
+//	conf, err := rglog.ParseConfigUsing(f, yaml.Unmarshal)
+func ParseConfigUsing(r io.Reader, unmarshal func(data []byte, v interface{}) error) (*Config, error) {
+	data, err := ioutil.ReadAll(r)
+	if err != nil {
+		return nil, erro.Wrap(err)
+	}
+	var v interface{}
+	if err := unmarshal(data, &v); err != nil {
+		return nil, erro.New("invalid config: ", err)
+	}
+	data, err = json.Marshal(normalizeConfigValue(v))
+	if err != nil {
+		return nil, erro.New("invalid config: ", err)
+	}
+	return parseConfig(data)
+}
+
+// map[interface{}]interface{} を返す YAML ライブラリもあるので、JSON にできるようにキーを文字列にする。
+func normalizeConfigValue(v interface{}) interface{} {
+	switch v := v.(type) {
+	case map[interface{}]interface{}:
+		m := map[string]interface{}{}
+		for key, val := range v {
+			m[fmt.Sprint(key)] = normalizeConfigValue(val)
+		}
+		return m
+	case map[string]interface{}:
+		m := map[string]interface{}{}
+		for key, val := range v {
+			m[key] = normalizeConfigValue(val)
+		}
+		return m
+	case []interface{}:
+		elems := make([]interface{}, len(v))
+		for i, elem := range v {
+			elems[i] = normalizeConfigValue(elem)
+		}
+		return elems
+	default:
+		return v
+	}
+}
+
+func parseConfig(data []byte) (*Config, error) {
+	var raw struct {
+		Loggers  map[string]json.RawMessage `json:"loggers"`
+		Handlers map[string]json.RawMessage `json:"handlers"`
+	}
+	if err := decodeStrictly(data, &raw); err != nil {
+		return nil, erro.New("invalid config: ", err)
+	}
+
+	conf := &Config{Loggers: map[string]*LoggerConfig{}, Handlers: map[string]HandlerConfig{}}
+	for name, data := range raw.Loggers {
+		var logConf LoggerConfig
+		if err := decodeStrictly(data, &logConf); err != nil {
+			return nil, erro.New("loggers."+strconv.Quote(name)+": ", err)
+		}
+		conf.Loggers[name] = &logConf
+	}
+	for name, data := range raw.Handlers {
+		var hndlConf HandlerConfig
+		if err := decodeStrictly(data, &hndlConf); err != nil {
+			return nil, erro.New("handlers."+strconv.Quote(name)+": ", err)
+		}
+		conf.Handlers[name] = hndlConf
+	}
+	return conf, nil
+}
+
+// 知らない項目があったらエラーにする。数値は json.Number にする。
+func decodeStrictly(data []byte, v interface{}) error {
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.DisallowUnknownFields()
+	dec.UseNumber()
+	return dec.Decode(v)
+}
+
+// mgr に設定を反映させる。
+// ハンドラを全てつくれてから反映させるので、エラーのときは何も変わらない。
+// 設定し直すなら Configurator を使う。
+func (conf *Config) Apply(mgr logger.Manager) error {
+	return NewConfigurator(mgr).Apply(conf)
+}
+
+func newHandler(path string, hndlConf HandlerConfig) (handler.Handler, error) {
+	params := &HandlerParams{path: path, vals: hndlConf, used: map[string]bool{}}
+	typ, err := params.String("type", "")
+	if err != nil {
+		return nil, erro.Wrap(err)
+	} else if typ == "" {
+		return nil, erro.New(path + ".type: required")
+	}
+	lv, err := params.Level("level", level.ALL)
+	if err != nil {
+		return nil, erro.Wrap(err)
+	}
+	sampling, err := samplingConfig(params)
+	if err != nil {
+		return nil, erro.Wrap(err)
+	}
+	dedupWindow, err := dedupConfig(params)
+	if err != nil {
+		return nil, erro.Wrap(err)
+	}
+	filter, err := filterConfig(params)
+	if err != nil {
+		return nil, erro.Wrap(err)
+	}
+
+	factory := lookupHandlerFactory(typ)
+	if factory == nil {
+		return nil, erro.New(path + ".type: unknown handler type " + typ)
+	}
+	hndl, err := factory(params)
+	if err != nil {
+		return nil, erro.Wrap(err)
+	}
+	if err := params.checkUnused(); err != nil {
+		hndl.Close()
+		return nil, erro.Wrap(err)
+	}
+	hndl.SetLevel(lv)
+	base := hndl
+	if sampling != nil {
+		hndl, err = handler.NewSamplingHandler(hndl, *sampling)
+		if err != nil {
+			base.Close()
+			return nil, erro.Wrap(err)
+		}
+	}
+	if dedupWindow > 0 {
+		hndl = handler.NewDedupHandler(hndl, dedupWindow)
+	}
+	if filter != nil {
+		// 捨てるログで間引きやまとめが乱れないように、一番外側。
+		hndl = handler.NewFilterHandler(hndl, filter)
+	}
+	if hndl != base {
+		hndl = &wrappedHandler{hndl, base}
+	}
+	return hndl, nil
+}
+
+// 間引き等で包んだハンドラ。
+// 包むハンドラは中のハンドラを閉じないので、つくった側として外側を閉じてから中を閉じる。
+type wrappedHandler struct {
+	handler.Handler
+	base handler.Handler
+}
+
+func (hndl *wrappedHandler) Close() error {
+	err := hndl.Handler.Close()
+	if err2 := hndl.base.Close(); err == nil {
+		err = err2
+	}
+	return erro.Wrap(err)
+}
+
+// "filter": {"logger": "a/b", "file": "handler/*.go", "message": "^health",
+//
+//	"field": {"key": "id", "value": 1}, "level": {"min": "DEBUG", "max": "INFO"},
+//	"and": [{...}, ...], "or": [{...}, ...], "not": {...}}
+//
+// 1 つのオブジェクトに複数の項目を書くと、全てに合うログだけを通す。
+// 無ければ nil を返す。
+func filterConfig(params *HandlerParams) (handler.Filter, error) {
+	sub, err := params.Params("filter")
+	if err != nil {
+		return nil, erro.Wrap(err)
+	} else if sub == nil {
+		return nil, nil
+	}
+	return newFilter(sub)
+}
+
+func newFilter(params *HandlerParams) (handler.Filter, error) {
+	filters := []handler.Filter{}
+
+	if name, err := params.String("logger", ""); err != nil {
+		return nil, erro.Wrap(err)
+	} else if name != "" {
+		filters = append(filters, handler.LoggerFilter(name))
+	}
+	if pattern, err := params.String("file", ""); err != nil {
+		return nil, erro.Wrap(err)
+	} else if pattern != "" {
+		filter, err := handler.FileFilter(pattern)
+		if err != nil {
+			return nil, erro.New(params.path + ".file: invalid pattern " + pattern)
+		}
+		filters = append(filters, filter)
+	}
+	if pattern, err := params.String("message", ""); err != nil {
+		return nil, erro.Wrap(err)
+	} else if pattern != "" {
+		re, err := regexp.Compile(pattern)
+		if err != nil {
+			return nil, erro.New(params.path + ".message: invalid pattern " + pattern)
+		}
+		filters = append(filters, handler.MessageFilter(re))
+	}
+	if sub, err := params.Params("field"); err != nil {
+		return nil, erro.Wrap(err)
+	} else if sub != nil {
+		key, err := sub.RequiredString("key")
+		if err != nil {
+			return nil, erro.Wrap(err)
+		}
+		val, ok := sub.value("value")
+		if !ok {
+			return nil, erro.New(sub.path + ".value: required")
+		}
+		filters = append(filters, handler.FieldFilter(key, val))
+	}
+	if sub, err := params.Params("level"); err != nil {
+		return nil, erro.Wrap(err)
+	} else if sub != nil {
+		min, err := sub.Level("min", level.ALL)
+		if err != nil {
+			return nil, erro.Wrap(err)
+		}
+		max, err := sub.Level("max", level.FATAL)
+		if err != nil {
+			return nil, erro.Wrap(err)
+		}
+		filters = append(filters, handler.LevelFilter(min, max))
+	}
+	for _, op := range []struct {
+		key     string
+		combine func(filters ...handler.Filter) handler.Filter
+	}{
+		{"and", handler.AndFilter},
+		{"or", handler.OrFilter},
+	} {
+		subs, err := params.ParamsList(op.key)
+		if err != nil {
+			return nil, erro.Wrap(err)
+		} else if subs == nil {
+			continue
+		}
+		elems := []handler.Filter{}
+		for _, sub := range subs {
+			elem, err := newFilter(sub)
+			if err != nil {
+				return nil, erro.Wrap(err)
+			}
+			elems = append(elems, elem)
+		}
+		filters = append(filters, op.combine(elems...))
+	}
+	if sub, err := params.Params("not"); err != nil {
+		return nil, erro.Wrap(err)
+	} else if sub != nil {
+		elem, err := newFilter(sub)
+		if err != nil {
+			return nil, erro.Wrap(err)
+		}
+		filters = append(filters, handler.NotFilter(elem))
+	}
+
+	switch len(filters) {
+	case 0:
+		return nil, erro.New(params.path + ": empty filter")
+	case 1:
+		return filters[0], nil
+	default:
+		return handler.AndFilter(filters...), nil
+	}
+}
+
+// "dedup": {"window": "10s"}
+// 無ければ 0 を返す。
+func dedupConfig(params *HandlerParams) (time.Duration, error) {
+	sub, err := params.Params("dedup")
+	if err != nil {
+		return 0, erro.Wrap(err)
+	} else if sub == nil {
+		return 0, nil
+	}
+
+	window, err := sub.Duration("window", 10*time.Second)
+	if err != nil {
+		return 0, erro.Wrap(err)
+	} else if window <= 0 {
+		return 0, erro.New(sub.path + ".window: not positive")
+	}
+	return window, nil
+}
+
+// "sampling": {"interval": "1s", "first": 10, "thereafter": 100, "by": "callSite"}
+// by は callSite か message。
+func samplingConfig(params *HandlerParams) (*handler.SamplingConfig, error) {
+	sub, err := params.Params("sampling")
+	if err != nil {
+		return nil, erro.Wrap(err)
+	} else if sub == nil {
+		return nil, nil
+	}
+
+	interval, err := sub.Duration("interval", time.Second)
+	if err != nil {
+		return nil, erro.Wrap(err)
+	} else if interval <= 0 {
+		return nil, erro.New(sub.path + ".interval: not positive")
+	}
+	first, err := sub.Int("first", 0)
+	if err != nil {
+		return nil, erro.Wrap(err)
+	}
+	thereafter, err := sub.Int("thereafter", 0)
+	if err != nil {
+		return nil, erro.Wrap(err)
+	}
+	by, err := sub.String("by", "callSite")
+	if err != nil {
+		return nil, erro.Wrap(err)
+	}
+	var key handler.SamplingKey
+	switch by {
+	case "callSite":
+		key = handler.SampleByCallSite
+	case "message":
+		key = handler.SampleByMessage
+	default:
+		return nil, erro.New(sub.path + ".by: unknown key " + by)
+	}
+	return &handler.SamplingConfig{Interval: interval, First: int(first), Thereafter: int(thereafter), Key: key}, nil
+}
+
+func sortedKeys(m map[string]*LoggerConfig) []string {
+	keys := []string{}
+	for key := range m {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+func sortedHandlerKeys(m map[string]HandlerConfig) []string {
+	keys := []string{}
+	for key := range m {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+func sortedAttachedKeys(m map[string][]string) []string {
+	keys := []string{}
+	for key := range m {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// ハンドラの設定項目を読む。
+// エラーには設定ファイル中の位置が付く。
+type HandlerParams struct {
+	// handlers."{ハンドラ名}"。
+	path string
+	vals map[string]interface{}
+	// 読んだ項目。読まれなかった項目はエラーにする。
+	used map[string]bool
+	// Params で読んだ入れ子の項目。
+	subs []*HandlerParams
+}
+
+func (params *HandlerParams) value(key string) (interface{}, bool) {
+	params.used[key] = true
+	val, ok := params.vals[key]
+	return val, ok && val != nil
+}
+
+// 文字列の項目を読む。無ければ defaultVal を返す。
+func (params *HandlerParams) String(key, defaultVal string) (string, error) {
+	val, ok := params.value(key)
+	if !ok {
+		return defaultVal, nil
+	}
+	s, ok := val.(string)
+	if !ok {
+		return "", erro.New(params.path + "." + key + ": not a string")
+	}
+	return s, nil
+}
+
+// 文字列の必須項目を読む。
+func (params *HandlerParams) RequiredString(key string) (string, error) {
+	s, err := params.String(key, "")
+	if err != nil {
+		return "", erro.Wrap(err)
+	} else if s == "" {
+		return "", erro.New(params.path + "." + key + ": required")
+	}
+	return s, nil
+}
+
+// 整数の項目を読む。無ければ defaultVal を返す。
+func (params *HandlerParams) Int(key string, defaultVal int64) (int64, error) {
+	val, ok := params.value(key)
+	if !ok {
+		return defaultVal, nil
+	}
+	var n int64
+	var err error
+	switch v := val.(type) {
+	case json.Number:
+		n, err = v.Int64()
+	case int:
+		n = int64(v)
+	case int64:
+		n = v
+	case float64:
+		n = int64(v)
+		if float64(n) != v {
+			err = erro.New("not an integer")
+		}
+	default:
+		err = erro.New("not an integer")
+	}
+	if err != nil {
+		return 0, erro.New(params.path + "." + key + ": not an integer")
+	}
+	return n, nil
+}
+
+// 真偽値の項目を読む。無ければ defaultVal を返す。
+func (params *HandlerParams) Bool(key string, defaultVal bool) (bool, error) {
+	val, ok := params.value(key)
+	if !ok {
+		return defaultVal, nil
+	}
+	b, ok := val.(bool)
+	if !ok {
+		return false, erro.New(params.path + "." + key + ": not a boolean")
+	}
+	return b, nil
+}
+
+// 時間の項目を "10s" のような文字列で読む。無ければ defaultVal を返す。
+func (params *HandlerParams) Duration(key string, defaultVal time.Duration) (time.Duration, error) {
+	s, err := params.String(key, "")
+	if err != nil {
+		return 0, erro.Wrap(err)
+	} else if s == "" {
+		return defaultVal, nil
+	}
+	d, err := time.ParseDuration(s)
+	if err != nil {
+		return 0, erro.New(params.path + "." + key + ": invalid duration " + s)
+	}
+	return d, nil
+}
+
+// 入れ子になった項目を読む。無ければ nil を返す。
+func (params *HandlerParams) Params(key string) (*HandlerParams, error) {
+	val, ok := params.value(key)
+	if !ok {
+		return nil, nil
+	}
+	vals, ok := val.(map[string]interface{})
+	if !ok {
+		return nil, erro.New(params.path + "." + key + ": not an object")
+	}
+	sub := &HandlerParams{path: params.path + "." + key, vals: vals, used: map[string]bool{}}
+	params.subs = append(params.subs, sub)
+	return sub, nil
+}
+
+// 入れ子になった項目の配列を読む。無ければ nil を返す。
+func (params *HandlerParams) ParamsList(key string) ([]*HandlerParams, error) {
+	val, ok := params.value(key)
+	if !ok {
+		return nil, nil
+	}
+	elems, ok := val.([]interface{})
+	if !ok {
+		return nil, erro.New(params.path + "." + key + ": not an array")
+	}
+	subs := []*HandlerParams{}
+	for i, elem := range elems {
+		path := params.path + "." + key + "[" + strconv.Itoa(i) + "]"
+		vals, ok := elem.(map[string]interface{})
+		if !ok {
+			return nil, erro.New(path + ": not an object")
+		}
+		sub := &HandlerParams{path: path, vals: vals, used: map[string]bool{}}
+		params.subs = append(params.subs, sub)
+		subs = append(subs, sub)
+	}
+	return subs, nil
+}
+
+// 重要度の項目を読む。無ければ defaultVal を返す。
+func (params *HandlerParams) Level(key string, defaultVal level.Level) (level.Level, error) {
+	label, err := params.String(key, "")
+	if err != nil {
+		return 0, erro.Wrap(err)
+	} else if label == "" {
+		return defaultVal, nil
+	}
+	lv, err := level.ValueOf(label)
+	if err != nil {
+		return 0, erro.New(params.path + "." + key + ": invalid level " + label)
+	}
+	return lv, nil
+}
+
+// 書式の項目を読む。無ければ defaultVal を返す。
+// 書式は RegisterFormatter で登録した名前で指定する。
+func (params *HandlerParams) Formatter(key string, defaultVal handler.Formatter) (handler.Formatter, error) {
+	name, err := params.String(key, "")
+	if err != nil {
+		return nil, erro.Wrap(err)
+	} else if name == "" {
+		return defaultVal, nil
+	}
+	fmter := lookupFormatter(name)
+	if fmter == nil {
+		return nil, erro.New(params.path + "." + key + ": unknown formatter " + name)
+	}
+	return fmter, nil
+}
+
+func (params *HandlerParams) checkUnused() error {
+	keys := []string{}
+	for key := range params.vals {
+		if !params.used[key] {
+			keys = append(keys, key)
+		}
+	}
+	if len(keys) > 0 {
+		sort.Strings(keys)
+		return erro.New(params.path + "." + keys[0] + ": unknown key")
+	}
+	for _, sub := range params.subs {
+		if err := sub.checkUnused(); err != nil {
+			return erro.Wrap(err)
+		}
+	}
+	return nil
+}
+
+// 設定ファイルの params からハンドラをつくる。
+type HandlerFactory func(params *HandlerParams) (handler.Handler, error)
+
+var registry = struct {
+	lock       sync.Mutex
+	factories  map[string]HandlerFactory
+	formatters map[string]handler.Formatter
+}{
+	factories:  map[string]HandlerFactory{},
+	formatters: map[string]handler.Formatter{},
+}
+
+// 設定ファイルで使えるハンドラの種類を登録する。
+// 同じ種類が既に登録されていたら置き換える。
+func RegisterHandlerFactory(typ string, factory HandlerFactory) {
+	registry.lock.Lock()
+	defer registry.lock.Unlock()
+
+	registry.factories[typ] = factory
+}
+
+func lookupHandlerFactory(typ string) HandlerFactory {
+	registry.lock.Lock()
+	defer registry.lock.Unlock()
+
+	return registry.factories[typ]
+}
+
+// 設定ファイルで使える書式を登録する。
+// 同じ名前が既に登録されていたら置き換える。
+func RegisterFormatter(name string, fmter handler.Formatter) {
+	registry.lock.Lock()
+	defer registry.lock.Unlock()
+
+	registry.formatters[name] = fmter
+}
+
+func lookupFormatter(name string) handler.Formatter {
+	registry.lock.Lock()
+	defer registry.lock.Unlock()
+
+	return registry.formatters[name]
+}
+
+// 標準のハンドラと書式。
+func init() {
+	RegisterFormatter("simple", handler.SimpleFormatter)
+	RegisterFormatter("levelOnly", handler.LevelOnlyFormatter)
+	RegisterFormatter("detail", &handler.DetailFormatter{Date: true, Hostname: true, Pid: true, Goroutine: true, Logger: true, Function: true})
+	RegisterFormatter("json", handler.JsonFormatter)
+
+	// "formatter"。
+	RegisterHandlerFactory("console", func(params *HandlerParams) (handler.Handler, error) {
+		fmter, err := params.Formatter("formatter", handler.SimpleFormatter)
+		if err != nil {
+			return nil, erro.Wrap(err)
+		}
+		return handler.NewConsoleHandlerUsing(fmter), nil
+	})
+	// "path", "formatter"。
+	RegisterHandlerFactory("file", func(params *HandlerParams) (handler.Handler, error) {
+		path, err := params.RequiredString("path")
+		if err != nil {
+			return nil, erro.Wrap(err)
+		}
+		fmter, err := params.Formatter("formatter", handler.SimpleFormatter)
+		if err != nil {
+			return nil, erro.Wrap(err)
+		}
+		return handler.NewFileHandlerUsing(path, fmter)
+	})
+	// "path", "limit", "num", "formatter"。
+	RegisterHandlerFactory("rotate", func(params *HandlerParams) (handler.Handler, error) {
+		path, err := params.RequiredString("path")
+		if err != nil {
+			return nil, erro.Wrap(err)
+		}
+		limit, err := params.Int("limit", 10*1024*1024)
+		if err != nil {
+			return nil, erro.Wrap(err)
+		}
+		num, err := params.Int("num", 10)
+		if err != nil {
+			return nil, erro.Wrap(err)
+		}
+		fmter, err := params.Formatter("formatter", handler.SimpleFormatter)
+		if err != nil {
+			return nil, erro.Wrap(err)
+		}
+		return handler.NewRotateHandlerUsing(path, limit, int(num), fmter), nil
+	})
+	// "addr", "tag", "formatter"。
+	RegisterHandlerFactory("syslog", func(params *HandlerParams) (handler.Handler, error) {
+		addr, err := params.String("addr", "")
+		if err != nil {
+			return nil, erro.Wrap(err)
+		}
+		tag, err := params.RequiredString("tag")
+		if err != nil {
+			return nil, erro.Wrap(err)
+		}
+		fmter, err := params.Formatter("formatter", nil)
+		if err != nil {
+			return nil, erro.Wrap(err)
+		} else if fmter == nil {
+			return handler.NewSyslogHandlerTo(addr, tag), nil
+		}
+		return handler.NewSyslogHandlerToUsing(addr, tag, fmter), nil
+	})
+	// "addr", "tag"。
+	RegisterHandlerFactory("fluentd", func(params *HandlerParams) (handler.Handler, error) {
+		addr, err := params.String("addr", "localhost:24224")
+		if err != nil {
+			return nil, erro.Wrap(err)
+		}
+		tag, err := params.RequiredString("tag")
+		if err != nil {
+			return nil, erro.Wrap(err)
+		}
+		return handler.NewFluentdHandler(addr, tag), nil
+	})
+}
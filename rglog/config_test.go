@@ -0,0 +1,309 @@
+// Copyright 2015 realglobe, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package rglog
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/realglobe-Inc/go-lib/rglog/handler"
+	"github.com/realglobe-Inc/go-lib/rglog/level"
+	"github.com/realglobe-Inc/go-lib/rglog/logger"
+)
+
+var testMemHndls = map[string]*handler.MemoryHandler{}
+
+func init() {
+	// "name", "formatter"。
+	RegisterHandlerFactory("test-memory", func(params *HandlerParams) (handler.Handler, error) {
+		name, err := params.RequiredString("name")
+		if err != nil {
+			return nil, err
+		}
+		fmter, err := params.Formatter("formatter", handler.SimpleFormatter)
+		if err != nil {
+			return nil, err
+		}
+		hndl := handler.NewMemoryHandlerUsing(fmter)
+		testMemHndls[name] = hndl
+		return hndl, nil
+	})
+}
+
+func TestConfigApply(t *testing.T) {
+	conf, err := ParseConfig(strings.NewReader(`{
+  "loggers": {
+    "": {"level": "info", "useParent": false, "handlers": ["all"]},
+    "a/b": {"level": "DEBUG", "handlers": ["warn", "all"]}
+  },
+  "handlers": {
+    "all": {"type": "test-memory", "name": "all", "formatter": "levelOnly"},
+    "warn": {"type": "test-memory", "name": "warn", "level": "WARN"}
+  }
+}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	mgr := logger.NewLockLoggerManager()
+	old := handler.NewMemoryHandler()
+	mgr.Logger("a/b").AddHandler("old", old)
+	if err := conf.Apply(mgr); err != nil {
+		t.Fatal(err)
+	}
+
+	root := mgr.Logger("")
+	if root.Level() != level.INFO || root.UseParent() {
+		t.Fatal(root.Level(), root.UseParent())
+	}
+	log := mgr.Logger("a/b")
+	if log.Level() != level.DEBUG || !log.UseParent() {
+		t.Fatal(log.Level(), log.UseParent())
+	} else if hndls := log.Handlers(); len(hndls) != 2 || hndls["old"] != nil {
+		t.Fatal(hndls)
+	} else if testMemHndls["warn"].Level() != level.WARN {
+		t.Fatal(testMemHndls["warn"].Level())
+	}
+
+	log.Debug("debug")
+	log.Warn("warn")
+	root.Debug("root")
+	if dump := testMemHndls["warn"].Dump(); strings.Contains(dump, "debug") || !strings.Contains(dump, "warn") {
+		t.Fatal(dump)
+	} else if dump := testMemHndls["all"].Dump(); dump != "[DEB] debug\n[WAR] warn\n[WAR] warn\n" {
+		// a/b に登録したものと、ルートに登録したもの。ルートは INFO 以上。
+		t.Fatal(dump)
+	} else if old.Dump() != "" {
+		t.Fatal(old.Dump())
+	}
+}
+
+func TestConfigError(t *testing.T) {
+	for _, c := range []struct {
+		conf string
+		path string
+	}{
+		{`{"loggers": {"a": {"levl": "INFO"}}}`, `loggers."a"`},
+		{`{"loggers": {"a": {"level": "INF"}}}`, `loggers."a"`},
+		{`{"loggers": {"a": {"handlers": ["b"]}}}`, `loggers."a".handlers`},
+		{`{"handlers": {"b": {"name": "b"}}}`, `handlers."b".type`},
+		{`{"handlers": {"b": {"type": "unknown"}}}`, `handlers."b".type`},
+		{`{"handlers": {"b": {"type": "test-memory"}}}`, `handlers."b".name`},
+		{`{"handlers": {"b": {"type": "test-memory", "name": "b", "limit": 10}}}`, `handlers."b".limit`},
+		{`{"handlers": {"b": {"type": "test-memory", "name": 1}}}`, `handlers."b".name`},
+		{`{"handlers": {"b": {"type": "test-memory", "name": "b", "formatter": "unknown"}}}`, `handlers."b".formatter`},
+		{`{"handlers": {"b": {"type": "test-memory", "name": "b", "level": "unknown"}}}`, `handlers."b".level`},
+		{`{"handlers": {"b": {"type": "rotate", "path": "/tmp/a", "limit": "1k"}}}`, `handlers."b".limit`},
+		{`{"handlers": {"b": {"type": "test-memory", "name": "b", "sampling": 1}}}`, `handlers."b".sampling`},
+		{`{"handlers": {"b": {"type": "test-memory", "name": "b", "sampling": {"interval": "0s"}}}}`, `handlers."b".sampling.interval`},
+		{`{"handlers": {"b": {"type": "test-memory", "name": "b", "sampling": {"by": "level"}}}}`, `handlers."b".sampling.by`},
+		{`{"handlers": {"b": {"type": "test-memory", "name": "b", "sampling": {"frist": 1}}}}`, `handlers."b".sampling.frist`},
+		{`{"handlers": {"b": {"type": "test-memory", "name": "b", "dedup": {"window": "-1s"}}}}`, `handlers."b".dedup.window`},
+		{`{"handlers": {"b": {"type": "test-memory", "name": "b", "filter": {}}}}`, `handlers."b".filter: empty filter`},
+		{`{"handlers": {"b": {"type": "test-memory", "name": "b", "filter": {"message": "("}}}}`, `handlers."b".filter.message`},
+		{`{"handlers": {"b": {"type": "test-memory", "name": "b", "filter": {"file": "["}}}}`, `handlers."b".filter.file`},
+		{`{"handlers": {"b": {"type": "test-memory", "name": "b", "filter": {"field": {"key": "id"}}}}}`, `handlers."b".filter.field.value`},
+		{`{"handlers": {"b": {"type": "test-memory", "name": "b", "filter": {"and": {}}}}}`, `handlers."b".filter.and`},
+		{`{"handlers": {"b": {"type": "test-memory", "name": "b", "filter": {"or": [{"logger": "a"}, {"lgoger": "b"}]}}}}`, `handlers."b".filter.or[1]`},
+		{`{"loggerz": {}}`, `invalid config`},
+	} {
+		conf, err := ParseConfig(strings.NewReader(c.conf))
+		if err == nil {
+			err = conf.Apply(logger.NewLockLoggerManager())
+		}
+		if err == nil {
+			t.Error(c.conf, "no error")
+		} else if !strings.Contains(err.Error(), c.path) {
+			t.Error(c.conf, err)
+		}
+	}
+}
+
+func TestConfigErrorNoChange(t *testing.T) {
+	conf, err := ParseConfig(strings.NewReader(`{
+  "loggers": {"a": {"level": "DEBUG", "handlers": ["b"]}}
+}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	mgr := logger.NewLockLoggerManager()
+	if err := conf.Apply(mgr); err == nil {
+		t.Fatal("no error")
+	} else if lv := mgr.Logger("a").Level(); lv == level.DEBUG {
+		t.Fatal(lv)
+	}
+}
+
+func TestConfigSampling(t *testing.T) {
+	conf, err := ParseConfig(strings.NewReader(`{
+  "loggers": {"a": {"level": "INFO", "useParent": false, "handlers": ["sampled"]}},
+  "handlers": {
+    "sampled": {"type": "test-memory", "name": "sampled", "formatter": "levelOnly", "sampling": {"interval": "1h", "first": 1, "by": "message"}}
+  }
+}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	mgr := logger.NewLockLoggerManager()
+	if err := conf.Apply(mgr); err != nil {
+		t.Fatal(err)
+	}
+
+	log := mgr.Logger("a")
+	for i := 0; i < 3; i++ {
+		log.Err("a")
+	}
+	mgr.Flush()
+	if dump := testMemHndls["sampled"].Dump(); dump != "[ERR] a\n[ERR] 2 similar records were suppressed: a suppressed=2\n" {
+		t.Fatal(dump)
+	}
+}
+
+func TestConfigDedup(t *testing.T) {
+	conf, err := ParseConfig(strings.NewReader(`{
+  "loggers": {"a": {"level": "INFO", "useParent": false, "handlers": ["dedup"]}},
+  "handlers": {
+    "dedup": {"type": "test-memory", "name": "dedup", "formatter": "levelOnly", "dedup": {"window": "1h"}}
+  }
+}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	mgr := logger.NewLockLoggerManager()
+	if err := conf.Apply(mgr); err != nil {
+		t.Fatal(err)
+	}
+
+	log := mgr.Logger("a")
+	for i := 0; i < 3; i++ {
+		log.Err("a")
+	}
+	mgr.Flush()
+	if dump := testMemHndls["dedup"].Dump(); dump != "[ERR] a\n[ERR] last message repeated 2 times repeated=2\n" {
+		t.Fatal(dump)
+	}
+}
+
+func TestConfigFilter(t *testing.T) {
+	conf, err := ParseConfig(strings.NewReader(`{
+  "loggers": {"a": {"level": "DEBUG", "useParent": false, "handlers": ["filtered"]}},
+  "handlers": {
+    "filtered": {"type": "test-memory", "name": "filtered", "formatter": "levelOnly", "filter": {
+      "or": [
+        {"logger": "a/b", "not": {"message": "^health"}},
+        {"field": {"key": "id", "value": 1}, "level": {"min": "WARN"}}
+      ]
+    }}
+  }
+}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	mgr := logger.NewLockLoggerManager()
+	if err := conf.Apply(mgr); err != nil {
+		t.Fatal(err)
+	}
+
+	mgr.Logger("a/b/c").Info("request")
+	mgr.Logger("a/b").Info("health check")
+	mgr.Logger("a").Info("other")
+	mgr.Logger("a").With("id", 1).Info("info with id")
+	mgr.Logger("a").With("id", 1).Err("error with id")
+	mgr.Flush()
+	if dump := testMemHndls["filtered"].Dump(); dump != "[INF] request\n[ERR] error with id id=1\n" {
+		t.Fatal(dump)
+	}
+}
+
+// 後のハンドラをつくれなかったら、先につくったハンドラは閉じる。
+func TestConfigErrorCloseHandlers(t *testing.T) {
+	conf, err := ParseConfig(strings.NewReader(`{
+  "handlers": {
+    "a": {"type": "test-close", "id": "config-error-a"},
+    "b": {"type": "unknown"}
+  }
+}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := conf.Apply(logger.NewLockLoggerManager()); err == nil {
+		t.Fatal("no error")
+	} else if hndl := getTestCloseHandler("config-error-a"); hndl == nil || !hndl.isClosed() {
+		t.Fatal(hndl)
+	}
+}
+
+// 間引き等で包んだハンドラも、Manager.Close で中まで閉じるか。
+func TestConfigWrappedClose(t *testing.T) {
+	conf, err := ParseConfig(strings.NewReader(`{
+  "loggers": {"a": {"handlers": ["a"]}},
+  "handlers": {
+    "a": {"type": "test-close", "id": "config-wrapped", "sampling": {"interval": "1h"}, "dedup": {"window": "1h"}, "filter": {"logger": "a"}}
+  }
+}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	mgr := logger.NewLockLoggerManager()
+	if err := conf.Apply(mgr); err != nil {
+		t.Fatal(err)
+	}
+	hndl := getTestCloseHandler("config-wrapped")
+	if hndl.isClosed() {
+		t.Fatal("closed before Close")
+	} else if err := mgr.Close(context.Background()); err != nil {
+		t.Fatal(err)
+	} else if !hndl.isClosed() {
+		t.Fatal("not closed")
+	}
+}
+
+func TestParseConfigUsing(t *testing.T) {
+	// YAML ライブラリの代わり。map[interface{}]interface{} を返すものもある。
+	unmarshal := func(data []byte, v interface{}) error {
+		*(v.(*interface{})) = map[interface{}]interface{}{
+			"loggers": map[interface{}]interface{}{
+				"a": map[interface{}]interface{}{"level": "DEBUG", "handlers": []interface{}{"b"}},
+			},
+			"handlers": map[interface{}]interface{}{
+				"b": map[interface{}]interface{}{"type": "test-memory", "name": "yaml", "formatter": "levelOnly"},
+			},
+		}
+		return nil
+	}
+	conf, err := ParseConfigUsing(strings.NewReader(""), unmarshal)
+	if err != nil {
+		t.Fatal(err)
+	}
+	mgr := logger.NewLockLoggerManager()
+	if err := conf.Apply(mgr); err != nil {
+		t.Fatal(err)
+	}
+	mgr.Logger("a").Debug("debug")
+	if dump := testMemHndls["yaml"].Dump(); dump != "[DEB] debug\n" {
+		t.Fatal(dump)
+	}
+
+	// 検査は JSON と同じ。
+	unmarshal = func(data []byte, v interface{}) error {
+		*(v.(*interface{})) = map[string]interface{}{"loggers": map[string]interface{}{"a": map[string]interface{}{"levl": "DEBUG"}}}
+		return nil
+	}
+	if _, err := ParseConfigUsing(strings.NewReader(""), unmarshal); err == nil || !strings.Contains(err.Error(), `loggers."a"`) {
+		t.Fatal(err)
+	}
+}
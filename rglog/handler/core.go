@@ -30,15 +30,18 @@ import (
 type coreHandler interface {
 	output(rec Record)
 	flush()
-	close()
+	close() error
 }
 
 // coreHandler をスレッドセーフにするラッパー。
 // ついでに別ゴルーチンでの書き込みにもなる。
 // output はノンブロッキング。
 // flush, close はブロッキング。
+// close した後の output, flush, close は何もしない。
 type synchronizedCoreHandler struct {
 	reqCh chan<- interface{}
+	// close を処理したら閉じられる。
+	doneCh <-chan struct{}
 }
 
 // 書き出し待機させる最大数。
@@ -56,29 +59,52 @@ type synchronizedFlushRequest struct {
 }
 
 type synchronizedCloseRequest struct {
-	ackCh chan<- struct{}
+	ackCh chan<- error
 }
 
 func (core *synchronizedCoreHandler) output(rec Record) {
-	core.reqCh <- &synchronizedOutputRequest{rec}
+	select {
+	case core.reqCh <- &synchronizedOutputRequest{rec}:
+	case <-core.doneCh:
+		// 閉じた後なので捨てる。
+	}
 }
 
 func (core *synchronizedCoreHandler) flush() {
 	ackCh := make(chan struct{}, 1)
-	core.reqCh <- &synchronizedFlushRequest{ackCh}
-	<-ackCh
+	select {
+	case core.reqCh <- &synchronizedFlushRequest{ackCh}:
+	case <-core.doneCh:
+		return
+	}
+	select {
+	case <-ackCh:
+	case <-core.doneCh:
+	}
 }
 
-func (core *synchronizedCoreHandler) close() {
-	ackCh := make(chan struct{}, 1)
-	core.reqCh <- &synchronizedCloseRequest{ackCh}
-	<-ackCh
+func (core *synchronizedCoreHandler) close() error {
+	ackCh := make(chan error, 1)
+	select {
+	case core.reqCh <- &synchronizedCloseRequest{ackCh}:
+	case <-core.doneCh:
+		return nil
+	}
+	select {
+	case err := <-ackCh:
+		return err
+	case <-core.doneCh:
+		// 他の close で閉じた。
+		return nil
+	}
 }
 
 func newSynchronizedCoreHandler(base coreHandler) coreHandler {
 	reqCh := make(chan interface{}, chCap)
+	doneCh := make(chan struct{})
 
 	go func() {
+		defer close(doneCh)
 		closed := false
 		for !closed {
 			func() { // パニックになったときも素知らぬ顔で次のリクエストを処理するために関数で括る。
@@ -114,7 +140,7 @@ func newSynchronizedCoreHandler(base coreHandler) coreHandler {
 		}
 	}()
 
-	return &synchronizedCoreHandler{reqCh}
+	return &synchronizedCoreHandler{reqCh, doneCh}
 }
 
 func handleSynchronizedRequest(base coreHandler, req interface{}) (closed bool) {
@@ -125,8 +151,9 @@ func handleSynchronizedRequest(base coreHandler, req interface{}) (closed bool)
 		defer func() { r.ackCh <- struct{}{} }()
 		base.flush()
 	case *synchronizedCloseRequest:
-		defer func() { r.ackCh <- struct{}{} }()
-		base.close()
+		var err error
+		defer func() { r.ackCh <- err }()
+		err = base.close()
 		return true
 	default:
 		panic("unknown request " + reflect.TypeOf(req).Name())
@@ -178,6 +205,6 @@ func (hndl *coreWrapper) Flush() {
 	hndl.base.flush()
 }
 
-func (hndl *coreWrapper) Close() {
-	hndl.base.close()
+func (hndl *coreWrapper) Close() error {
+	return hndl.base.close()
 }
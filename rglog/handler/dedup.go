@@ -0,0 +1,139 @@
+// Copyright 2015 realglobe, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package handler
+
+import (
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/realglobe-Inc/go-lib/rglog/level"
+)
+
+// 繰り返しをまとめたことを知らせるログに付ける付加情報のキー。
+const RepeatedKey = "repeated"
+
+// 続けて同じログが来たらまとめるハンドラ。
+type dedupHandler struct {
+	lock   sync.Mutex
+	base   Handler
+	window time.Duration
+
+	// 最後に書き出したログと、その日時。
+	first      Record
+	firstStart time.Time
+	// first の後に来た同じログの数と、その最後。
+	repeated int
+	last     Record
+	// 繰り返しの数を知らせるためのタイマー。
+	timer  *time.Timer
+	closed bool
+}
+
+// base に書き出すログのうち、重要度、呼び出し元、メッセージが同じものが続いたらまとめるハンドラをつくる。
+// 最初の 1 つは書き出し、window の間に続いた同じログは数えるだけにする。
+// 違うログが来たとき、window が過ぎたとき、Flush されたときに、"last message repeated N times" を書き出す。
+// 重要度は base のものを使う。
+// Close しても base は閉じない。base はつくった側が閉じる。
+func NewDedupHandler(base Handler, window time.Duration) Handler {
+	return &dedupHandler{base: base, window: window}
+}
+
+func (hndl *dedupHandler) Level() level.Level {
+	return hndl.base.Level()
+}
+
+func (hndl *dedupHandler) SetLevel(lv level.Level) {
+	hndl.base.SetLevel(lv)
+}
+
+func sameRecord(rec1, rec2 Record) bool {
+	return rec1.Level() == rec2.Level() &&
+		rec1.Line() == rec2.Line() &&
+		rec1.File() == rec2.File() &&
+		rec1.Message() == rec2.Message()
+}
+
+func (hndl *dedupHandler) Output(rec Record) {
+	if rec.Level().Lower(hndl.base.Level()) {
+		return
+	}
+
+	hndl.lock.Lock()
+	defer hndl.lock.Unlock()
+	if hndl.closed {
+		return
+	}
+
+	now := time.Now()
+	if hndl.first != nil && sameRecord(hndl.first, rec) && now.Sub(hndl.firstStart) < hndl.window {
+		hndl.repeated++
+		hndl.last = rec
+		if hndl.timer == nil {
+			hndl.timer = time.AfterFunc(hndl.window-now.Sub(hndl.firstStart), hndl.expire)
+		}
+		return
+	}
+
+	hndl.report()
+	hndl.base.Output(rec)
+	hndl.first = rec
+	hndl.firstStart = now
+}
+
+// 繰り返しの数を書き出して、まとめるのをやめる。
+// ロックは外で。
+func (hndl *dedupHandler) report() {
+	if hndl.timer != nil {
+		hndl.timer.Stop()
+		hndl.timer = nil
+	}
+	if hndl.repeated > 0 {
+		msg := "last message repeated " + strconv.Itoa(hndl.repeated) + " times"
+		hndl.base.Output(newSummaryRecord(hndl.last, msg, Field{RepeatedKey, hndl.repeated}))
+	}
+	hndl.first = nil
+	hndl.repeated = 0
+	hndl.last = nil
+}
+
+func (hndl *dedupHandler) expire() {
+	hndl.lock.Lock()
+	defer hndl.lock.Unlock()
+
+	if hndl.first != nil && time.Since(hndl.firstStart) >= hndl.window {
+		hndl.report()
+	}
+}
+
+func (hndl *dedupHandler) Flush() {
+	hndl.lock.Lock()
+	hndl.report()
+	hndl.lock.Unlock()
+
+	hndl.base.Flush()
+}
+
+// 繰り返しの数を書き出して、base を Flush する。
+// base は閉じない。
+func (hndl *dedupHandler) Close() error {
+	hndl.lock.Lock()
+	hndl.report()
+	hndl.closed = true
+	hndl.lock.Unlock()
+
+	hndl.base.Flush()
+	return nil
+}
@@ -0,0 +1,85 @@
+// Copyright 2015 realglobe, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package handler
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/realglobe-Inc/go-lib/rglog/level"
+)
+
+func TestDedupHandlerLevel(t *testing.T) {
+	testHandlerLevel(t, NewDedupHandler(NewNopHandler(), time.Second))
+}
+
+func TestDedupHandlerKeepBase(t *testing.T) {
+	testHandlerKeepBase(t, func(base Handler) Handler {
+		return NewDedupHandler(base, time.Hour)
+	})
+}
+
+func TestDedupHandlerOutput(t *testing.T) {
+	testHandlerOutput(t, NewDedupHandler(NewMemoryHandler(), time.Second))
+}
+
+func TestDedupHandlerRepeat(t *testing.T) {
+	base := NewMemoryHandlerUsing(LevelOnlyFormatter)
+	hndl := NewDedupHandler(base, time.Hour)
+
+	for i := 0; i < 3; i++ {
+		hndl.Output(&record{date: time.Now(), lv: level.ERR, file: "a.go", line: 1, msg: "a"})
+	}
+	hndl.Output(&record{date: time.Now(), lv: level.ERR, file: "a.go", line: 2, msg: "a"})
+	hndl.Output(&record{date: time.Now(), lv: level.WARN, file: "a.go", line: 2, msg: "a"})
+	hndl.Output(&record{date: time.Now(), lv: level.WARN, file: "a.go", line: 2, msg: "b"})
+	hndl.Output(&record{date: time.Now(), lv: level.WARN, file: "a.go", line: 2, msg: "b"})
+	if dump := base.Dump(); dump != "[ERR] a\n[ERR] last message repeated 2 times repeated=2\n[ERR] a\n[WAR] a\n[WAR] b\n" {
+		t.Fatal(dump)
+	}
+
+	hndl.Flush()
+	if dump := base.Dump(); !strings.HasSuffix(dump, "[WAR] b\n[WAR] last message repeated 1 times repeated=1\n") {
+		t.Fatal(dump)
+	}
+
+	// Flush の後は、また書き出す。
+	hndl.Output(&record{date: time.Now(), lv: level.WARN, file: "a.go", line: 2, msg: "b"})
+	if dump := base.Dump(); !strings.HasSuffix(dump, "repeated=1\n[WAR] b\n") {
+		t.Fatal(dump)
+	}
+}
+
+func TestDedupHandlerWindow(t *testing.T) {
+	base := NewMemoryHandlerUsing(LevelOnlyFormatter)
+	hndl := NewDedupHandler(base, 20*time.Millisecond)
+	defer hndl.Close()
+
+	hndl.Output(&record{date: time.Now(), lv: level.ERR, file: "a.go", line: 1, msg: "a"})
+	hndl.Output(&record{date: time.Now(), lv: level.ERR, file: "a.go", line: 1, msg: "a"})
+	// 何も書き出さなくても、繰り返しの数が書き出される。
+	for i := 0; !strings.Contains(base.Dump(), "repeated=1"); i++ {
+		if i > 100 {
+			t.Fatal(base.Dump())
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	hndl.Output(&record{date: time.Now(), lv: level.ERR, file: "a.go", line: 1, msg: "a"})
+	if dump := base.Dump(); dump != "[ERR] a\n[ERR] last message repeated 1 times repeated=1\n[ERR] a\n" {
+		t.Fatal(dump)
+	}
+}
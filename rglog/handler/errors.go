@@ -0,0 +1,75 @@
+// Copyright 2015 realglobe, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package handler
+
+import (
+	"errors"
+	"strings"
+)
+
+// スタックトレース付きのエラー。erro.Tracer とか。
+type stackTracer interface {
+	Cause() error
+	Stack() string
+}
+
+// エラーを素のエラーとスタックトレースに分ける。
+// fmt.Errorf の %w 等で包まれていても、中のスタックトレースを取り出す。
+// 包まれていた場合の素のエラーは、メッセージからスタックトレースを除いたもの。
+// スタックトレースが付いていなければ、err と "" を返す。
+func ErrorStack(err error) (cause error, stack string) {
+	if tr, ok := err.(stackTracer); ok {
+		return tr.Cause(), tr.Stack()
+	}
+	var tr stackTracer
+	if !errors.As(err, &tr) {
+		return err, ""
+	}
+	msg := strings.Replace(err.Error(), "\n"+tr.Stack(), "", 1)
+	return &unstackedError{msg, err}, tr.Stack()
+}
+
+// スタックトレース付きのエラーを包んだエラーから、メッセージのスタックトレースを除いたもの。
+// errors.Is 等のために元のエラーを返す Unwrap を持つ。
+type unstackedError struct {
+	msg string
+	err error
+}
+
+func (err *unstackedError) Error() string {
+	return err.msg
+}
+
+func (err *unstackedError) Unwrap() error {
+	return err.err
+}
+
+// エラーのスタックトレースを、1 行ずつタブで字下げして並べる。
+// スタックトレースが無ければ空。
+func formatStacks(errs []error) string {
+	buff := []byte{}
+	for _, err := range errs {
+		_, stack := ErrorStack(err)
+		if stack == "" {
+			continue
+		}
+		for _, line := range strings.Split(stack, "\n") {
+			buff = append(buff, '\t')
+			buff = append(buff, line...)
+			buff = append(buff, '\n')
+		}
+	}
+	return string(buff)
+}
@@ -0,0 +1,177 @@
+// Copyright 2015 realglobe, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package handler
+
+import (
+	"fmt"
+	"path"
+	"regexp"
+	"strings"
+	"sync/atomic"
+
+	"github.com/realglobe-Inc/go-lib/erro"
+	"github.com/realglobe-Inc/go-lib/rglog/level"
+)
+
+// ログを書き出すかどうかを決める。
+type Filter interface {
+	// 書き出すなら true。
+	Allow(rec Record) bool
+}
+
+// 関数を Filter として使う。
+type FilterFunc func(rec Record) bool
+
+func (f FilterFunc) Allow(rec Record) bool {
+	return f(rec)
+}
+
+// filter が通したログだけを base に書き出すハンドラ。
+type filterHandler struct {
+	base   Handler
+	filter Filter
+	// 閉じたら 1。
+	closed int32
+}
+
+// filter が通したログだけを base に書き出すハンドラをつくる。
+// 重要度は base のものを使う。
+// Close しても base は閉じない。base はつくった側が閉じる。
+func NewFilterHandler(base Handler, filter Filter) Handler {
+	return &filterHandler{base: base, filter: filter}
+}
+
+func (hndl *filterHandler) Level() level.Level {
+	return hndl.base.Level()
+}
+
+func (hndl *filterHandler) SetLevel(lv level.Level) {
+	hndl.base.SetLevel(lv)
+}
+
+func (hndl *filterHandler) Output(rec Record) {
+	if rec.Level().Lower(hndl.base.Level()) || atomic.LoadInt32(&hndl.closed) != 0 || !hndl.filter.Allow(rec) {
+		return
+	}
+	hndl.base.Output(rec)
+}
+
+func (hndl *filterHandler) Flush() {
+	hndl.base.Flush()
+}
+
+// base を Flush する。
+// base は閉じない。
+func (hndl *filterHandler) Close() error {
+	atomic.StoreInt32(&hndl.closed, 1)
+	hndl.base.Flush()
+	return nil
+}
+
+// ロガー名が name か、その子孫であるログを通す。
+// "a/b" なら "a/b" と "a/b/c" は通すが、"a/bc" は通さない。"" なら全て通す。
+func LoggerFilter(name string) Filter {
+	return FilterFunc(func(rec Record) bool {
+		logName := rec.LoggerName()
+		return name == "" || logName == name || strings.HasPrefix(logName, name+"/")
+	})
+}
+
+// ファイル名が glob パターン pattern に合うログを通す。
+// 合うかどうかは MatchFile で調べる。
+func FileFilter(pattern string) (Filter, error) {
+	if _, err := path.Match(pattern, ""); err != nil {
+		return nil, erro.New("invalid file pattern ", pattern)
+	}
+	return FilterFunc(func(rec Record) bool {
+		return MatchFile(pattern, rec.File())
+	}), nil
+}
+
+// glob パターン pattern が、ファイル名 file の末尾の / 区切りの要素に合うかどうか。
+// "handler/rotate*.go" は "a/b/handler/rotate.go" に合う。
+// パターンの要素数だけ file の末尾を取り出して path.Match で比べる。
+func MatchFile(pattern, file string) bool {
+	n := strings.Count(pattern, "/") + 1
+	pos := len(file)
+	for ; n > 0 && pos >= 0; n-- {
+		pos = strings.LastIndexByte(file[:pos], '/')
+	}
+	ok, _ := path.Match(pattern, file[pos+1:])
+	return ok
+}
+
+// メッセージが正規表現 re に合うログを通す。
+func MessageFilter(re *regexp.Regexp) Filter {
+	return FilterFunc(func(rec Record) bool {
+		return re.MatchString(rec.Message())
+	})
+}
+
+// 付加情報 key の値が val であるログを通す。
+// 値は fmt.Sprint した文字列で比べる。
+func FieldFilter(key string, val interface{}) Filter {
+	s := fmt.Sprint(val)
+	return FilterFunc(func(rec Record) bool {
+		for _, field := range rec.Fields() {
+			if field.Key == key && fmt.Sprint(field.Value) == s {
+				return true
+			}
+		}
+		return false
+	})
+}
+
+// 重要度が min 以上 max 以下のログを通す。
+// LevelFilter(level.DEBUG, level.INFO) なら INFO と DEBUG だけ通す。
+func LevelFilter(min, max level.Level) Filter {
+	return FilterFunc(func(rec Record) bool {
+		lv := rec.Level()
+		return !lv.Lower(min) && !lv.Higher(max)
+	})
+}
+
+// 全ての filters が通すログを通す。
+// filters が無ければ全て通す。
+func AndFilter(filters ...Filter) Filter {
+	return FilterFunc(func(rec Record) bool {
+		for _, filter := range filters {
+			if !filter.Allow(rec) {
+				return false
+			}
+		}
+		return true
+	})
+}
+
+// filters のどれかが通すログを通す。
+// filters が無ければ何も通さない。
+func OrFilter(filters ...Filter) Filter {
+	return FilterFunc(func(rec Record) bool {
+		for _, filter := range filters {
+			if filter.Allow(rec) {
+				return true
+			}
+		}
+		return false
+	})
+}
+
+// filter が通さないログを通す。
+func NotFilter(filter Filter) Filter {
+	return FilterFunc(func(rec Record) bool {
+		return !filter.Allow(rec)
+	})
+}
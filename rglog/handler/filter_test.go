@@ -0,0 +1,144 @@
+// Copyright 2015 realglobe, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package handler
+
+import (
+	"regexp"
+	"testing"
+
+	"github.com/realglobe-Inc/go-lib/rglog/level"
+)
+
+func TestFilterHandlerLevel(t *testing.T) {
+	testHandlerLevel(t, NewFilterHandler(NewMemoryHandler(), AndFilter()))
+}
+
+func TestFilterHandlerKeepBase(t *testing.T) {
+	testHandlerKeepBase(t, func(base Handler) Handler {
+		return NewFilterHandler(base, AndFilter())
+	})
+}
+
+func TestFilterHandlerOutput(t *testing.T) {
+	testHandlerOutput(t, NewFilterHandler(NewMemoryHandler(), AndFilter()))
+}
+
+func TestFilterHandler(t *testing.T) {
+	base := NewMemoryHandlerUsing(LevelOnlyFormatter)
+	hndl := NewFilterHandler(base, NotFilter(MessageFilter(regexp.MustCompile("^health"))))
+	hndl.Output(&record{lv: level.INFO, msg: "health check"})
+	hndl.Output(&record{lv: level.INFO, msg: "request"})
+
+	if dump := base.Dump(); dump != "[INF] request\n" {
+		t.Fatal(dump)
+	}
+}
+
+// ロガー名を変えたレコード。
+type namedRecord struct {
+	*record
+	name string
+}
+
+func (rec *namedRecord) LoggerName() string {
+	return rec.name
+}
+
+func TestLoggerFilter(t *testing.T) {
+	for _, c := range []struct {
+		name    string
+		logName string
+		ok      bool
+	}{
+		{"a/b", "a/b", true},
+		{"a/b", "a/b/c", true},
+		{"a/b", "a/bc", false},
+		{"a/b", "a", false},
+		{"", "a", true},
+	} {
+		if ok := LoggerFilter(c.name).Allow(&namedRecord{&record{}, c.logName}); ok != c.ok {
+			t.Error(c.name, c.logName, ok)
+		}
+	}
+}
+
+func TestMatchFile(t *testing.T) {
+	for _, c := range []struct {
+		pattern string
+		file    string
+		ok      bool
+	}{
+		{"rotate*.go", "a/handler/rotate.go", true},
+		{"handler/rotate*.go", "a/handler/rotate_test.go", true},
+		{"handler/rotate*.go", "a/logger/rotate.go", false},
+		{"handler/*", "handler/a.go", true},
+		{"a/handler/*.go", "handler/a.go", false},
+		{"*.go", "a.go", true},
+		{"b.go", "a/b.go/c.go", false},
+	} {
+		if ok := MatchFile(c.pattern, c.file); ok != c.ok {
+			t.Error(c.pattern, c.file, ok)
+		}
+	}
+
+	if _, err := FileFilter("["); err == nil {
+		t.Fatal("no error")
+	} else if filter, err := FileFilter("handler/*.go"); err != nil {
+		t.Fatal(err)
+	} else if !filter.Allow(&record{file: "a/handler/b.go"}) || filter.Allow(&record{file: "a/b.go"}) {
+		t.Fatal("wrong match")
+	}
+}
+
+func TestFieldFilter(t *testing.T) {
+	filter := FieldFilter("id", "1")
+	if !filter.Allow(&record{fields: []Field{{"a", 2}, {"id", 1}}}) {
+		t.Fatal("not allowed")
+	} else if filter.Allow(&record{fields: []Field{{"id", 2}}}) || filter.Allow(&record{}) {
+		t.Fatal("allowed")
+	}
+}
+
+func TestLevelFilter(t *testing.T) {
+	filter := LevelFilter(level.DEBUG, level.INFO)
+	for _, lv := range level.Values() {
+		ok := lv == level.INFO || lv == level.DEBUG
+		if filter.Allow(&record{lv: lv}) != ok {
+			t.Error(lv)
+		}
+	}
+}
+
+func TestCombinedFilter(t *testing.T) {
+	yes := FilterFunc(func(Record) bool { return true })
+	no := NotFilter(yes)
+	rec := &record{}
+	for i, c := range []struct {
+		filter Filter
+		ok     bool
+	}{
+		{AndFilter(), true},
+		{AndFilter(yes, yes), true},
+		{AndFilter(yes, no), false},
+		{OrFilter(), false},
+		{OrFilter(no, yes), true},
+		{OrFilter(no, no), false},
+		{NotFilter(no), true},
+	} {
+		if ok := c.filter.Allow(rec); ok != c.ok {
+			t.Error(i, ok)
+		}
+	}
+}
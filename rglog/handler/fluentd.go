@@ -18,6 +18,7 @@ import (
 	"container/list"
 	"fmt"
 	"io"
+	"math"
 	"net"
 	"os"
 	"strconv"
@@ -49,7 +50,16 @@ func (core *fluentdCoreHandler) output(rec Record) {
 	//     "level": "INFO",
 	//     "file": "github.com/realglobe-Inc/go-lib/rglog/handler",
 	//     "line": 39,
-	//     "message": "Unko!"
+	//     "message": "Unko!",
+	//     "logger": "a/b/c",
+	//     "function": "github.com/realglobe-Inc/go-lib/rglog/handler.f",
+	//     "seq": 12,
+	//     "pid": 345,
+	//     "host": "localhost",
+	//     "goroutine": 6,
+	//     "errors": [{"error": "{素のエラー}", "stack": "{スタックトレース}"}, ...],
+	//     "{キー}": {値},
+	//     ...
 	//   }
 	// ]
 	//
@@ -64,20 +74,45 @@ func (core *fluentdCoreHandler) output(rec Record) {
 
 	buff = append(buff, messagePackInteger(rec.Date().Unix())...)
 
-	// fixmap 4.
-	buff = append(buff, 0x80|4)
-
-	buff = append(buff, messagePackString("level")...)
-	buff = append(buff, messagePackString(rec.Level().String())...)
+	// 書いたキーの数をマップの大きさにするので、中身を先につくる。
+	body := []byte{}
+	size := 0
+	put := func(key string, val []byte) {
+		body = append(body, messagePackString(key)...)
+		body = append(body, val...)
+		size++
+	}
 
-	buff = append(buff, messagePackString("file")...)
-	buff = append(buff, messagePackString(rec.File())...)
+	put("level", messagePackString(rec.Level().String()))
+	put("file", messagePackString(rec.File()))
+	put("line", messagePackInteger(int64(rec.Line())))
+	put("message", messagePackString(rec.Message()))
+	put("logger", messagePackString(rec.LoggerName()))
+	put("function", messagePackString(rec.Function()))
+	put("seq", messagePackUnsignedInteger(rec.Sequence()))
+	put("pid", messagePackInteger(int64(rec.Pid())))
+	put("host", messagePackString(rec.Hostname()))
+	put("goroutine", messagePackUnsignedInteger(rec.Goroutine()))
+
+	if errs := rec.Errors(); len(errs) > 0 {
+		val := messagePackArrayHeader(len(errs))
+		for _, err := range errs {
+			cause, stack := ErrorStack(err)
+			val = append(val, messagePackMapHeader(2)...)
+			val = append(val, messagePackString("error")...)
+			val = append(val, messagePackString(cause.Error())...)
+			val = append(val, messagePackString("stack")...)
+			val = append(val, messagePackString(stack)...)
+		}
+		put("errors", val)
+	}
 
-	buff = append(buff, messagePackString("line")...)
-	buff = append(buff, messagePackInteger(int64(rec.Line()))...)
+	for _, field := range objectFields(rec.Fields()) {
+		put(field.Key, messagePackValue(field.Value))
+	}
 
-	buff = append(buff, messagePackString("message")...)
-	buff = append(buff, messagePackString(rec.Message())...)
+	buff = append(buff, messagePackMapHeader(size)...)
+	buff = append(buff, body...)
 
 	const ( // てきとう。
 		writeSize  = 4096
@@ -89,6 +124,96 @@ func (core *fluentdCoreHandler) output(rec Record) {
 	core.flushCore(writeSize)
 }
 
+func messagePackMapHeader(length int) []byte {
+	if length < (1 << 4) {
+		// fixmap.
+		return []byte{byte(0x80 | length)}
+	} else if length < (1 << 16) {
+		// map16.
+		return []byte{0xde, byte((length & (0xff << 8)) >> 8), byte(length & 0xff)}
+	} else {
+		// map32.
+		return []byte{0xdf, byte((length & (0xff << 24)) >> 24), byte((length & (0xff << 16)) >> 16), byte((length & (0xff << 8)) >> 8), byte(length & 0xff)}
+	}
+}
+
+func messagePackArrayHeader(length int) []byte {
+	if length < (1 << 4) {
+		// fixarray.
+		return []byte{byte(0x90 | length)}
+	} else if length < (1 << 16) {
+		// array16.
+		return []byte{0xdc, byte((length & (0xff << 8)) >> 8), byte(length & 0xff)}
+	} else {
+		// array32.
+		return []byte{0xdd, byte((length & (0xff << 24)) >> 24), byte((length & (0xff << 16)) >> 16), byte((length & (0xff << 8)) >> 8), byte(length & 0xff)}
+	}
+}
+
+// 付加情報の値を変換する。
+// 数値、真偽値、nil 以外は文字列にする。
+func messagePackValue(val interface{}) []byte {
+	switch v := val.(type) {
+	case nil:
+		return []byte{0xc0}
+	case bool:
+		if v {
+			return []byte{0xc3}
+		}
+		return []byte{0xc2}
+	case int:
+		return messagePackInteger(int64(v))
+	case int8:
+		return messagePackInteger(int64(v))
+	case int16:
+		return messagePackInteger(int64(v))
+	case int32:
+		return messagePackInteger(int64(v))
+	case int64:
+		return messagePackInteger(v)
+	case uint8:
+		return messagePackInteger(int64(v))
+	case uint16:
+		return messagePackInteger(int64(v))
+	case uint32:
+		return messagePackInteger(int64(v))
+	case uint:
+		return messagePackUnsignedInteger(uint64(v))
+	case uint64:
+		return messagePackUnsignedInteger(v)
+	case float32:
+		return messagePackFloat(float64(v))
+	case float64:
+		return messagePackFloat(v)
+	case string:
+		return messagePackString(v)
+	default:
+		return messagePackString(fmt.Sprint(v))
+	}
+}
+
+func messagePackUnsignedInteger(val uint64) []byte {
+	if val < (1 << 63) {
+		return messagePackInteger(int64(val))
+	}
+	// uint64.
+	buff := []byte{0xcf}
+	for i := 7; i >= 0; i-- {
+		buff = append(buff, byte(val>>(8*uint(i))))
+	}
+	return buff
+}
+
+func messagePackFloat(val float64) []byte {
+	// float64.
+	bits := math.Float64bits(val)
+	buff := []byte{0xcb}
+	for i := 7; i >= 0; i-- {
+		buff = append(buff, byte(bits>>(8*uint(i))))
+	}
+	return buff
+}
+
 func messagePackString(val string) []byte {
 	buff := []byte{}
 	if length := len(val); length < (1 << 4) {
@@ -167,14 +292,18 @@ func (core *fluentdCoreHandler) flushCore(writeSize int) {
 	}
 }
 
-func (core *fluentdCoreHandler) close() {
+func (core *fluentdCoreHandler) close() error {
 	if core.conn == nil {
-		return
+		return nil
 	}
 	core.flush()
 	core.buff.close()
-	core.conn.Close()
+	err := core.conn.Close()
 	core.conn = nil
+	if err != nil {
+		return erro.Wrap(err)
+	}
+	return nil
 }
 
 func NewFluentdHandler(addr, tag string) Handler {
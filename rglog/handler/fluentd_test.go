@@ -22,6 +22,7 @@ import (
 	"testing"
 	"time"
 
+	"github.com/realglobe-Inc/go-lib/erro"
 	"github.com/realglobe-Inc/go-lib/rglog/level"
 )
 
@@ -200,6 +201,58 @@ func TestFluentdHandlerConnectionCut(t *testing.T) {
 	}
 }
 
+// エラーのスタックトレースが errors 項目に入るか。
+func TestFluentdHandlerErrors(t *testing.T) {
+	lis, err := net.Listen("tcp", "localhost:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer lis.Close()
+
+	dataCh := make(chan []byte, 1)
+	go func() {
+		conn, err := lis.Accept()
+		if err != nil {
+			dataCh <- nil
+			return
+		}
+		defer conn.Close()
+		buff := make([]byte, 8192)
+		n, _ := conn.Read(buff)
+		dataCh <- buff[:n]
+	}()
+
+	hndl := NewFluentdHandler(lis.Addr().String(), "rglog.test")
+	defer hndl.Close()
+
+	e := erro.New("test error")
+	date := time.Now()
+	hndl.Output(&record{date: date, lv: level.ERR, msg: "test test error", errs: []error{e}, fields: []Field{{"level", "x"}, {"k", 1}, {"k", 2}}})
+	hndl.Flush()
+
+	_, stack := ErrorStack(e)
+	want := append(messagePackValue("errors"), messagePackArrayHeader(1)...)
+	want = append(want, messagePackMapHeader(2)...)
+	want = append(want, messagePackValue("error")...)
+	want = append(want, messagePackValue("test error")...)
+	want = append(want, messagePackValue("stack")...)
+	want = append(want, messagePackValue(stack)...)
+	data := <-dataCh
+	if !bytes.Contains(data, want) {
+		t.Fatal(data)
+	}
+
+	// 予約キー 10 個と errors と _level と k。
+	head := append([]byte{0x90 | 3}, messagePackString("rglog.test")...)
+	head = append(head, messagePackInteger(date.Unix())...)
+	head = append(head, messagePackMapHeader(13)...)
+	if !bytes.HasPrefix(data, head) {
+		t.Fatal(data)
+	} else if !bytes.HasSuffix(data, append(messagePackString("k"), messagePackValue(2)...)) {
+		t.Fatal(data)
+	}
+}
+
 func BenchmarkFluentdHandler(b *testing.B) {
 	if fluentdAddr == "" {
 		b.SkipNow()
@@ -207,3 +260,33 @@ func BenchmarkFluentdHandler(b *testing.B) {
 
 	benchmarkHandler(b, NewFluentdHandler(fluentdAddr, "rglog.test"))
 }
+
+func TestMessagePackValue(t *testing.T) {
+	for _, c := range []struct {
+		val  interface{}
+		buff []byte
+	}{
+		{nil, []byte{0xc0}},
+		{true, []byte{0xc3}},
+		{false, []byte{0xc2}},
+		{1, []byte{0xd0, 1}},
+		{uint64(1 << 63), []byte{0xcf, 0x80, 0, 0, 0, 0, 0, 0, 0}},
+		{1.0, []byte{0xcb, 0x3f, 0xf0, 0, 0, 0, 0, 0, 0}},
+		{"ab", []byte{0xa2, 'a', 'b'}},
+		{errors.New("ab"), []byte{0xa2, 'a', 'b'}},
+	} {
+		if buff := messagePackValue(c.val); !bytes.Equal(buff, c.buff) {
+			t.Fatal(c.val, buff, c.buff)
+		}
+	}
+}
+
+func TestMessagePackMapHeader(t *testing.T) {
+	if buff := messagePackMapHeader(15); !bytes.Equal(buff, []byte{0x8f}) {
+		t.Fatal(buff)
+	} else if buff := messagePackMapHeader(16); !bytes.Equal(buff, []byte{0xde, 0, 16}) {
+		t.Fatal(buff)
+	} else if buff := messagePackMapHeader(1 << 16); !bytes.Equal(buff, []byte{0xdf, 0, 1, 0, 0}) {
+		t.Fatal(buff)
+	}
+}
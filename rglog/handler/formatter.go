@@ -17,6 +17,7 @@ package handler
 import (
 	"fmt"
 	"strconv"
+	"strings"
 
 	"github.com/realglobe-Inc/go-lib/rglog/level"
 )
@@ -25,7 +26,8 @@ type Formatter interface {
 	Format(rec Record) []byte
 }
 
-// {日時} {レベル} {ファイル名}:{行番号} {メッセージ}
+// {日時} {レベル} {ファイル名}:{行番号} {メッセージ} {キー}={値}...
+// エラーのスタックトレースは次の行からタブで字下げして書く。以下の書式も同じ。
 type simpleFormatter struct{}
 
 var SimpleFormatter = &simpleFormatter{}
@@ -47,18 +49,102 @@ func (formatter simpleFormatter) Format(rec Record) []byte {
 	hour, min, sec := rec.Date().Clock()
 	microSec := rec.Date().Nanosecond() / 1000
 
-	buff := fmt.Sprintf("%04d/%02d/%02d %02d:%02d:%02d.%06d %."+strconv.Itoa(lvWidth)+"v %s:%d %s\n",
-		year, int(month), day, hour, min, sec, microSec, rec.Level(), rec.File(), rec.Line(), rec.Message())
+	buff := fmt.Sprintf("%04d/%02d/%02d %02d:%02d:%02d.%06d %."+strconv.Itoa(lvWidth)+"v %s:%d %s%s\n%s",
+		year, int(month), day, hour, min, sec, microSec, rec.Level(), rec.File(), rec.Line(), rec.Message(), formatFields(rec.Fields()), formatStacks(rec.Errors()))
 
 	return []byte(buff)
 }
 
-// [{レベル}] {メッセージ}
+// 項目を選べる書式。
+// {日時} {レベル} {ホスト名} {プロセス ID} g{ゴルーチン ID} #{通し番号} [{ロガー名}] {関数名} {ファイル名}:{行番号} {メッセージ} {キー}={値}...
+// の内、true にした項目と、レベル、ファイル名、行番号、メッセージ、付加情報を書き出す。
+// 全部 false なら syslog 用の書式になり、Date だけ true なら SimpleFormatter と同じになる。
+type DetailFormatter struct {
+	Date      bool
+	Hostname  bool
+	Pid       bool
+	Goroutine bool
+	Sequence  bool
+	Logger    bool
+	Function  bool
+}
+
+func (formatter *DetailFormatter) Format(rec Record) []byte {
+	buff := []byte{}
+	if formatter.Date {
+		buff = append(buff, rec.Date().Format("2006/01/02 15:04:05.000000 ")...)
+	}
+	buff = append(buff, fmt.Sprintf("%."+strconv.Itoa(lvWidth)+"v ", rec.Level())...)
+	if formatter.Hostname {
+		buff = append(buff, rec.Hostname()...)
+		buff = append(buff, ' ')
+	}
+	if formatter.Pid {
+		buff = strconv.AppendInt(buff, int64(rec.Pid()), 10)
+		buff = append(buff, ' ')
+	}
+	if formatter.Goroutine {
+		buff = append(buff, 'g')
+		buff = strconv.AppendUint(buff, rec.Goroutine(), 10)
+		buff = append(buff, ' ')
+	}
+	if formatter.Sequence {
+		buff = append(buff, '#')
+		buff = strconv.AppendUint(buff, rec.Sequence(), 10)
+		buff = append(buff, ' ')
+	}
+	if formatter.Logger {
+		buff = append(buff, '[')
+		buff = append(buff, rec.LoggerName()...)
+		buff = append(buff, "] "...)
+	}
+	if formatter.Function {
+		buff = append(buff, rec.Function()...)
+		buff = append(buff, ' ')
+	}
+	buff = append(buff, rec.File()...)
+	buff = append(buff, ':')
+	buff = strconv.AppendInt(buff, int64(rec.Line()), 10)
+	buff = append(buff, ' ')
+	buff = append(buff, rec.Message()...)
+	buff = append(buff, formatFields(rec.Fields())...)
+	buff = append(buff, '\n')
+	buff = append(buff, formatStacks(rec.Errors())...)
+	return buff
+}
+
+// [{レベル}] {メッセージ} {キー}={値}...
 type levelOnlyFormatter struct{}
 
 var LevelOnlyFormatter = &levelOnlyFormatter{}
 
 func (formatter levelOnlyFormatter) Format(rec Record) []byte {
-	buff := fmt.Sprintf("[%."+strconv.Itoa(lvWidth)+"v] %s\n", rec.Level(), rec.Message())
+	buff := fmt.Sprintf("[%."+strconv.Itoa(lvWidth)+"v] %s%s\n%s", rec.Level(), rec.Message(), formatFields(rec.Fields()), formatStacks(rec.Errors()))
 	return []byte(buff)
 }
+
+// 付加情報を " {キー}={値}..." の形にする。
+// 空白等を含む値は引用符で括る。
+func formatFields(fields []Field) string {
+	if len(fields) == 0 {
+		return ""
+	}
+
+	buff := []byte{}
+	for _, field := range fields {
+		buff = append(buff, ' ')
+		buff = append(buff, quoteIfNeeded(field.Key)...)
+		buff = append(buff, '=')
+		buff = append(buff, quoteIfNeeded(fmt.Sprint(field.Value))...)
+	}
+	return string(buff)
+}
+
+func quoteIfNeeded(s string) string {
+	if s == "" || strings.IndexFunc(s, func(r rune) bool {
+		return r <= ' ' || r == '=' || r == '"' || r == 0x7f
+	}) >= 0 {
+		return strconv.Quote(s)
+	}
+	return s
+}
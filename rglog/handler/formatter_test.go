@@ -0,0 +1,143 @@
+// Copyright 2015 realglobe, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package handler
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/realglobe-Inc/go-lib/erro"
+	"github.com/realglobe-Inc/go-lib/rglog/level"
+)
+
+func TestSimpleFormatterFields(t *testing.T) {
+	rec := &record{date: time.Now(), lv: level.INFO, file: "a.go", line: 1, msg: "test",
+		fields: []Field{{"id", 1}, {"user", "a b"}, {"empty", ""}}}
+	buff := string(SimpleFormatter.Format(rec))
+	if !strings.HasSuffix(buff, ` a.go:1 test id=1 user="a b" empty=""`+"\n") {
+		t.Fatal(buff)
+	}
+}
+
+func TestLevelOnlyFormatterFields(t *testing.T) {
+	rec := &record{date: time.Now(), lv: level.INFO, msg: "test", fields: []Field{{"a=b", 1.5}}}
+	if buff := string(LevelOnlyFormatter.Format(rec)); buff != `[INF] test "a=b"=1.5`+"\n" {
+		t.Fatal(buff)
+	}
+}
+
+func TestDetailFormatter(t *testing.T) {
+	date := time.Date(2015, 6, 1, 12, 34, 56, 789012000, time.UTC)
+	rec := &record{date: date, lv: level.INFO, file: "a.go", line: 1, msg: "test", fields: []Field{{"id", 1}}}
+
+	if buff := string((&DetailFormatter{}).Format(rec)); buff != "INF a.go:1 test id=1\n" {
+		t.Fatal(buff)
+	} else if buff := string((&DetailFormatter{Date: true}).Format(rec)); buff != string(SimpleFormatter.Format(rec)) {
+		t.Fatal(buff, string(SimpleFormatter.Format(rec)))
+	}
+
+	fmter := &DetailFormatter{true, true, true, true, true, true, true}
+	if buff := string(fmter.Format(rec)); buff != "2015/06/01 12:34:56.789012 INF localhost 2 g3 #1 [a/b/c] a/b/c.f a.go:1 test id=1\n" {
+		t.Fatal(buff)
+	}
+}
+
+func TestFormatterStack(t *testing.T) {
+	err := erro.New("test error")
+	rec := &record{lv: level.ERR, msg: "test test error", errs: []error{err, errors.New("no stack")}}
+
+	_, stack := ErrorStack(err)
+	block := "\t" + strings.Replace(stack, "\n", "\n\t", -1) + "\n"
+	for _, fmter := range []Formatter{SimpleFormatter, LevelOnlyFormatter, &DetailFormatter{}} {
+		buff := string(fmter.Format(rec))
+		if !strings.HasSuffix(buff, " test test error\n"+block) {
+			t.Error(buff)
+		}
+	}
+}
+
+// 包まれたスタックトレース付きエラーも分けられるか。
+func TestErrorStack(t *testing.T) {
+	err := erro.New("test error")
+	_, stack := ErrorStack(err)
+	if stack == "" {
+		t.Fatal("no stack")
+	}
+
+	wrapped := fmt.Errorf("wrap: %w", err)
+	if cause, stack2 := ErrorStack(wrapped); stack2 != stack {
+		t.Error(stack2)
+	} else if cause.Error() != "wrap: test error" {
+		t.Error(cause)
+	} else if !errors.Is(cause, err) {
+		t.Error(cause)
+	}
+
+	plain := errors.New("no stack")
+	if cause, stack := ErrorStack(plain); cause != plain || stack != "" {
+		t.Error(cause, stack)
+	}
+}
+
+func TestJsonFormatter(t *testing.T) {
+	date := time.Date(2015, 6, 1, 12, 34, 56, 789012000, time.UTC)
+	err := erro.New("test error")
+	rec := &record{date: date, lv: level.INFO, file: "a.go", line: 1, msg: "test", errs: []error{err},
+		fields: []Field{{"id", 1}, {"level", "x"}, {"err", err}, {"ch", make(chan int)}, {"id", 2}}}
+
+	buff := JsonFormatter.Format(rec)
+	if !strings.HasSuffix(string(buff), "}\n") || strings.Count(string(buff), "\n") != 1 {
+		t.Fatal(string(buff))
+	}
+	if n := strings.Count(string(buff), `"id":`); n != 1 {
+		t.Fatal(n, string(buff))
+	}
+	var m map[string]interface{}
+	if err := json.Unmarshal(buff, &m); err != nil {
+		t.Fatal(err, string(buff))
+	}
+	_, stack := ErrorStack(err)
+	for key, val := range map[string]interface{}{
+		"time":      "2015-06-01T12:34:56.789012Z",
+		"level":     "INFO",
+		"file":      "a.go",
+		"line":      1.0,
+		"message":   "test",
+		"logger":    "a/b/c",
+		"function":  "a/b/c.f",
+		"seq":       1.0,
+		"pid":       2.0,
+		"host":      "localhost",
+		"goroutine": 3.0,
+		"id":        2.0,
+		"_level":    "x",
+		"err":       "test error",
+	} {
+		if m[key] != val {
+			t.Error(key, m[key], val)
+		}
+	}
+	if errs, ok := m["errors"].([]interface{}); !ok || len(errs) != 1 {
+		t.Fatal(m["errors"])
+	} else if e := errs[0].(map[string]interface{}); e["error"] != "test error" || e["stack"] != stack {
+		t.Fatal(e)
+	} else if s, ok := m["ch"].(string); !ok || !strings.HasPrefix(s, "0x") {
+		t.Fatal(m["ch"])
+	}
+}
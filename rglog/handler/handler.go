@@ -35,7 +35,9 @@ type Handler interface {
 	// バッファを使っているなら、低層に書き出す。
 	Flush()
 
-	Close()
+	// 書き出し先を閉じる。
+	// 閉じた後の Output は捨てる。
+	Close() error
 }
 
 type Record interface {
@@ -44,4 +46,69 @@ type Record interface {
 	File() string
 	Line() int
 	Message() string
+	// 付加情報。
+	Fields() []Field
+	// ログの引数に入っていたエラー。
+	// スタックトレース付きのエラーでも、Message には素のエラーだけが入っている。
+	// スタックトレースは ErrorStack で取り出す。
+	Errors() []error
+
+	// ログを取ったロガーの名前。
+	LoggerName() string
+	// ログを取った関数の名前。
+	Function() string
+	// プロセス内でのログの通し番号。
+	Sequence() uint64
+	// プロセス ID。
+	Pid() int
+	// ホスト名。
+	Hostname() string
+	// ログを取ったゴルーチンの ID。
+	Goroutine() uint64
+}
+
+// ログに付ける付加情報。
+type Field struct {
+	Key   string
+	Value interface{}
+}
+
+// JSON や MessagePack のオブジェクトで付加情報のキーに使えないキー。
+// fluentd では time を別に送るが、JSON と同じキーになるように time も避ける。
+var reservedKeys = map[string]bool{
+	"time":      true,
+	"level":     true,
+	"file":      true,
+	"line":      true,
+	"message":   true,
+	"logger":    true,
+	"function":  true,
+	"seq":       true,
+	"pid":       true,
+	"host":      true,
+	"goroutine": true,
+	"errors":    true,
+}
+
+// 付加情報をオブジェクトに書く形に直す。
+// reservedKeys のキーには前に "_" を付ける。
+// 同じキーが 2 つあると困るので、後の値で前の値を上書きし、最初の位置に書く。
+func objectFields(fields []Field) []Field {
+	if len(fields) == 0 {
+		return nil
+	}
+	objFields := make([]Field, 0, len(fields))
+	idxs := make(map[string]int, len(fields))
+	for _, field := range fields {
+		if reservedKeys[field.Key] {
+			field.Key = "_" + field.Key
+		}
+		if i, ok := idxs[field.Key]; ok {
+			objFields[i].Value = field.Value
+			continue
+		}
+		idxs[field.Key] = len(objFields)
+		objFields = append(objFields, field)
+	}
+	return objFields
 }
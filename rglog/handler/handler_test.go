@@ -15,6 +15,7 @@
 package handler
 
 import (
+	"reflect"
 	"strconv"
 	"testing"
 	"time"
@@ -42,13 +43,41 @@ func testHandlerOutput(t *testing.T, hndl Handler) {
 	hndl.SetLevel(level.INFO)
 
 	for _, lv := range level.Values() {
-		hndl.Output(&record{time.Now(), lv, "test", 0, lv.String()})
+		hndl.Output(&record{date: time.Now(), lv: lv, file: "test", msg: lv.String()})
 	}
 
 	hndl.Flush()
 	hndl.Close()
 }
 
+// 包むハンドラが、閉じても base を閉じず、閉じた後のログを base に渡さないか。
+func testHandlerKeepBase(t *testing.T, wrap func(base Handler) Handler) {
+	base := &closeCountHandler{MemoryHandler: NewMemoryHandlerUsing(LevelOnlyFormatter)}
+	hndl := wrap(base)
+	hndl.Output(&record{date: time.Now(), lv: level.INFO, file: "a.go", line: 1, msg: "before"})
+	if err := hndl.Close(); err != nil {
+		t.Fatal(err)
+	}
+	hndl.Output(&record{date: time.Now(), lv: level.INFO, file: "a.go", line: 2, msg: "after"})
+
+	if base.n != 0 {
+		t.Error(base.n)
+	} else if dump := base.Dump(); dump != "[INF] before\n" {
+		t.Error(dump)
+	}
+}
+
+// Close された回数を数えるハンドラ。
+type closeCountHandler struct {
+	*MemoryHandler
+	n int
+}
+
+func (hndl *closeCountHandler) Close() error {
+	hndl.n++
+	return nil
+}
+
 func benchmarkHandler(b *testing.B, hndl Handler) {
 	defer hndl.Close()
 	hndl.SetLevel(level.ALL)
@@ -56,16 +85,18 @@ func benchmarkHandler(b *testing.B, hndl Handler) {
 	b.ResetTimer()
 	date := time.Now()
 	for i := 0; i < b.N; i++ {
-		hndl.Output(&record{date.Add(time.Duration(i) * time.Nanosecond), level.INFO, "test", 0, strconv.Itoa(i)})
+		hndl.Output(&record{date: date.Add(time.Duration(i) * time.Nanosecond), lv: level.INFO, file: "test", msg: strconv.Itoa(i)})
 	}
 }
 
 type record struct {
-	date time.Time
-	lv   level.Level
-	file string
-	line int
-	msg  string
+	date   time.Time
+	lv     level.Level
+	file   string
+	line   int
+	msg    string
+	fields []Field
+	errs   []error
 }
 
 func (rec *record) Date() time.Time {
@@ -83,3 +114,60 @@ func (rec *record) Line() int {
 func (rec *record) Message() string {
 	return rec.msg
 }
+func (rec *record) Fields() []Field {
+	return rec.fields
+}
+func (rec *record) Errors() []error {
+	return rec.errs
+}
+func (rec *record) LoggerName() string {
+	return "a/b/c"
+}
+func (rec *record) Function() string {
+	return "a/b/c.f"
+}
+func (rec *record) Sequence() uint64 {
+	return 1
+}
+func (rec *record) Pid() int {
+	return 2
+}
+func (rec *record) Hostname() string {
+	return "localhost"
+}
+func (rec *record) Goroutine() uint64 {
+	return 3
+}
+
+// 閉じた後に使っても詰まらないことの確認。
+func testHandlerOutputAfterClose(t *testing.T, hndl Handler) {
+	if err := hndl.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		for i := 0; i < 2*chCap; i++ {
+			hndl.Output(&record{date: time.Now(), lv: level.INFO, file: "test", msg: strconv.Itoa(i)})
+		}
+		hndl.Flush()
+		hndl.Close()
+	}()
+
+	select {
+	case <-done:
+	case <-time.After(10 * time.Second):
+		t.Fatal("blocked")
+	}
+}
+
+func TestObjectFields(t *testing.T) {
+	fields := objectFields([]Field{{"a", 1}, {"level", 2}, {"b", 3}, {"a", 4}, {"_level", 5}})
+	if want := []Field{{"a", 4}, {"_level", 5}, {"b", 3}}; !reflect.DeepEqual(fields, want) {
+		t.Error(fields, want)
+	}
+	if fields := objectFields(nil); len(fields) != 0 {
+		t.Error(fields)
+	}
+}
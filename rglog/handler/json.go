@@ -0,0 +1,108 @@
+// Copyright 2015 realglobe, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package handler
+
+import (
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"time"
+)
+
+// 1 行に 1 つの JSON オブジェクトを書く書式。
+//
+//	{"time":"2015-06-01T12:34:56.789012Z","level":"INFO","file":"a.go","line":1,"message":"test",
+//	 "logger":"a/b/c","function":"a/b/c.f","seq":1,"pid":2,"host":"localhost","goroutine":3,
+//	 "errors":[{"error":"{素のエラー}","stack":"{スタックトレース}"}],"{キー}":{値}}
+//
+// errors はエラーがあるときだけ。
+// 付加情報のキーが他の項目と重なったら、前に "_" を付ける。
+// 付加情報のキーが重なったら、後の値を書く。
+// JSON にできない値は fmt.Sprint で文字列にする。
+type jsonFormatter struct{}
+
+var JsonFormatter = &jsonFormatter{}
+
+func (formatter *jsonFormatter) Format(rec Record) []byte {
+	buff := []byte{'{'}
+	buff = appendJsonKey(buff, "time", true)
+	buff = appendJsonValue(buff, rec.Date().Format(time.RFC3339Nano))
+	buff = appendJsonKey(buff, "level", false)
+	buff = appendJsonValue(buff, rec.Level().String())
+	buff = appendJsonKey(buff, "file", false)
+	buff = appendJsonValue(buff, rec.File())
+	buff = appendJsonKey(buff, "line", false)
+	buff = strconv.AppendInt(buff, int64(rec.Line()), 10)
+	buff = appendJsonKey(buff, "message", false)
+	buff = appendJsonValue(buff, rec.Message())
+	buff = appendJsonKey(buff, "logger", false)
+	buff = appendJsonValue(buff, rec.LoggerName())
+	buff = appendJsonKey(buff, "function", false)
+	buff = appendJsonValue(buff, rec.Function())
+	buff = appendJsonKey(buff, "seq", false)
+	buff = strconv.AppendUint(buff, rec.Sequence(), 10)
+	buff = appendJsonKey(buff, "pid", false)
+	buff = strconv.AppendInt(buff, int64(rec.Pid()), 10)
+	buff = appendJsonKey(buff, "host", false)
+	buff = appendJsonValue(buff, rec.Hostname())
+	buff = appendJsonKey(buff, "goroutine", false)
+	buff = strconv.AppendUint(buff, rec.Goroutine(), 10)
+
+	if errs := rec.Errors(); len(errs) > 0 {
+		buff = appendJsonKey(buff, "errors", false)
+		buff = append(buff, '[')
+		for i, err := range errs {
+			if i > 0 {
+				buff = append(buff, ',')
+			}
+			cause, stack := ErrorStack(err)
+			buff = append(buff, '{')
+			buff = appendJsonKey(buff, "error", true)
+			buff = appendJsonValue(buff, cause.Error())
+			buff = appendJsonKey(buff, "stack", false)
+			buff = appendJsonValue(buff, stack)
+			buff = append(buff, '}')
+		}
+		buff = append(buff, ']')
+	}
+
+	for _, field := range objectFields(rec.Fields()) {
+		buff = appendJsonKey(buff, field.Key, false)
+		buff = appendJsonValue(buff, field.Value)
+	}
+
+	buff = append(buff, '}', '\n')
+	return buff
+}
+
+func appendJsonKey(buff []byte, key string, first bool) []byte {
+	if !first {
+		buff = append(buff, ',')
+	}
+	buff = appendJsonValue(buff, key)
+	return append(buff, ':')
+}
+
+func appendJsonValue(buff []byte, val interface{}) []byte {
+	if err, ok := val.(error); ok {
+		cause, _ := ErrorStack(err)
+		val = cause.Error()
+	}
+	data, err := json.Marshal(val)
+	if err != nil {
+		data, _ = json.Marshal(fmt.Sprint(val))
+	}
+	return append(buff, data...)
+}
@@ -38,5 +38,8 @@ func newMemoryHandlerUsing(fmter Formatter) *MemoryHandler {
 }
 
 func (hndl *MemoryHandler) Dump() string {
+	hndl.lock.Lock()
+	defer hndl.lock.Unlock()
+
 	return hndl.buff.String()
 }
@@ -49,4 +49,6 @@ func (hndl *nopHandler) Output(rec Record) {}
 
 func (hndl *nopHandler) Flush() {}
 
-func (hndl *nopHandler) Close() {}
+func (hndl *nopHandler) Close() error {
+	return nil
+}
@@ -150,14 +150,18 @@ func rotateFile(path string, num int) error {
 	return nil
 }
 
-func (core *rotateCoreHandler) close() {
+func (core *rotateCoreHandler) close() error {
 	if core.file == nil {
-		return
+		return nil
 	}
 	core.flush()
 	core.buff.flush()
-	core.file.Close()
+	err := core.file.Close()
 	core.file = nil
+	if err != nil {
+		return erro.Wrap(err)
+	}
+	return nil
 }
 
 func NewRotateHandler(path string, limit int64, num int) Handler {
@@ -141,3 +141,21 @@ func BenchmarkRotateHandler(b *testing.B) {
 
 	benchmarkHandler(b, hndl)
 }
+
+func TestRotateHandlerOutputAfterClose(t *testing.T) {
+	path, err := testFilePath()
+	if err != nil {
+		t.Fatal(err)
+	}
+	hndl := NewRotateHandler(path, 1<<20, 10)
+	defer os.Remove(path)
+
+	hndl.Output(&record{date: time.Now(), lv: level.INFO, file: "test", msg: "a"})
+	testHandlerOutputAfterClose(t, hndl)
+
+	if buff, err := ioutil.ReadFile(path); err != nil {
+		t.Fatal(err)
+	} else if strings.Count(string(buff), "\n") != 1 {
+		t.Fatal(string(buff))
+	}
+}
@@ -0,0 +1,260 @@
+// Copyright 2015 realglobe, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package handler
+
+import (
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/realglobe-Inc/go-lib/erro"
+	"github.com/realglobe-Inc/go-lib/rglog/level"
+)
+
+// 間引きで数える単位。
+type SamplingKey int
+
+const (
+	// 重要度と呼び出し元ごとに数える。
+	SampleByCallSite SamplingKey = iota
+	// 重要度とメッセージごとに数える。
+	SampleByMessage
+)
+
+// 間引き方。
+type SamplingConfig struct {
+	// 数え直す間隔。
+	Interval time.Duration
+	// 間隔ごとに、最初のこの数までは全て書き出す。
+	First int
+	// First を超えたら、この数ごとに 1 つ書き出す。0 なら書き出さない。
+	Thereafter int
+	// 数える単位。
+	Key SamplingKey
+}
+
+// 間引かれたことを知らせるログに付ける付加情報のキー。
+const SuppressedKey = "suppressed"
+
+// 同時に数える単位の数の上限。
+// 超えた分は数え直す時期が過ぎたものが片付くまで間引かない。
+const maxSamplingCounters = 10000
+
+// 同じ所から大量に出るログを間引くハンドラ。
+type samplingHandler struct {
+	lock sync.Mutex
+	// 数えた順に base に書き出すためのロック。
+	// lock を持ったまま取り、取れたら lock を外して書き出す。
+	outLock sync.Mutex
+	base    Handler
+	conf    SamplingConfig
+	closed  bool
+
+	counters map[samplingKey]*samplingCounter
+	// 数え終わったものを片付けて、間引いた数を知らせるためのタイマー。
+	// 数えているものがある間は動かしておく。
+	timer *time.Timer
+}
+
+type samplingKey struct {
+	lv   level.Level
+	file string
+	line int
+	msg  string
+}
+
+type samplingCounter struct {
+	// 数え始めた日時。
+	start time.Time
+	n     int
+	// 間引いた数と、最後に間引いたログ。
+	suppressed int
+	last       Record
+}
+
+// base に書き出すログを conf に従って間引くハンドラをつくる。
+// 間引いたログがあれば、数え直すときか Flush されたときに、間引いた数を base に書き出す。
+// 重要度は base のものを使う。
+// Close しても base は閉じない。base はつくった側が閉じる。
+// conf.Interval が正でなければエラーを返す。
+func NewSamplingHandler(base Handler, conf SamplingConfig) (Handler, error) {
+	if conf.Interval <= 0 {
+		return nil, erro.New("not positive interval ", conf.Interval)
+	}
+	return &samplingHandler{
+		base:     base,
+		conf:     conf,
+		counters: map[samplingKey]*samplingCounter{},
+	}, nil
+}
+
+func (hndl *samplingHandler) Level() level.Level {
+	return hndl.base.Level()
+}
+
+func (hndl *samplingHandler) SetLevel(lv level.Level) {
+	hndl.base.SetLevel(lv)
+}
+
+func (hndl *samplingHandler) Output(rec Record) {
+	if rec.Level().Lower(hndl.base.Level()) {
+		return
+	}
+
+	key := samplingKey{lv: rec.Level()}
+	if hndl.conf.Key == SampleByMessage {
+		key.msg = rec.Message()
+	} else {
+		key.file, key.line = rec.File(), rec.Line()
+	}
+
+	hndl.lock.Lock()
+	if hndl.closed {
+		hndl.lock.Unlock()
+		return
+	}
+	recs, pass := hndl.count(key, rec)
+	if pass {
+		recs = append(recs, rec)
+	}
+	hndl.unlockAndOutput(recs)
+}
+
+// lock を外して recs を base に書き出す。
+// 間引いた数のログが、それが数えたログより先に書き出されないように、outLock を取ってから lock を外す。
+// 書き出すものが無ければ、outLock は取らない。
+// lock は外で取る。
+func (hndl *samplingHandler) unlockAndOutput(recs []Record) {
+	if len(recs) == 0 {
+		hndl.lock.Unlock()
+		return
+	}
+	hndl.outLock.Lock()
+	hndl.lock.Unlock()
+	defer hndl.outLock.Unlock()
+
+	for _, rec := range recs {
+		hndl.base.Output(rec)
+	}
+}
+
+// rec を数えて、書き出すかどうかを返す。
+// 先に書き出す間引いた数のログも返す。
+// ロックは外で。
+func (hndl *samplingHandler) count(key samplingKey, rec Record) (recs []Record, pass bool) {
+	now := time.Now()
+	counter := hndl.counters[key]
+	if counter != nil && now.Sub(counter.start) >= hndl.conf.Interval {
+		recs = hndl.report(recs, counter)
+		counter = nil
+	}
+	if counter == nil {
+		if len(hndl.counters) >= maxSamplingCounters {
+			recs = hndl.sweepLocked(recs, false)
+		}
+		if len(hndl.counters) >= maxSamplingCounters {
+			// 数えきれないので、間引かない。
+			return recs, true
+		}
+		counter = &samplingCounter{start: now}
+		hndl.counters[key] = counter
+	}
+	if hndl.timer == nil {
+		// 数え終わったものを定期的に片付ける。
+		hndl.timer = time.AfterFunc(hndl.conf.Interval, hndl.sweep)
+	}
+
+	counter.n++
+	if counter.n <= hndl.conf.First || hndl.conf.Thereafter > 0 && (counter.n-hndl.conf.First)%hndl.conf.Thereafter == 0 {
+		return recs, true
+	}
+
+	counter.suppressed++
+	counter.last = rec
+	return recs, false
+}
+
+// 間引いた数を知らせるログを recs に足す。
+// ロックは外で。
+func (hndl *samplingHandler) report(recs []Record, counter *samplingCounter) []Record {
+	if counter.suppressed == 0 {
+		return recs
+	}
+	msg := strconv.Itoa(counter.suppressed) + " similar records were suppressed: " + counter.last.Message()
+	recs = append(recs, newSummaryRecord(counter.last, msg, Field{SuppressedKey, counter.suppressed}))
+	counter.suppressed = 0
+	counter.last = nil
+	return recs
+}
+
+// 数え直す時期を過ぎたものを片付ける。
+// 間引いた数を知らせるログを recs に足す。all なら全ての間引いた数を足す。
+// ロックは外で。
+func (hndl *samplingHandler) sweepLocked(recs []Record, all bool) []Record {
+	now := time.Now()
+	for key, counter := range hndl.counters {
+		if now.Sub(counter.start) >= hndl.conf.Interval {
+			recs = hndl.report(recs, counter)
+			delete(hndl.counters, key)
+		} else if all {
+			recs = hndl.report(recs, counter)
+		}
+	}
+
+	if hndl.timer != nil {
+		hndl.timer.Stop()
+		hndl.timer = nil
+	}
+	if len(hndl.counters) > 0 {
+		hndl.timer = time.AfterFunc(hndl.conf.Interval, hndl.sweep)
+	}
+	return recs
+}
+
+// ロックを取って片付けて、間引いた数を書き出す。
+func (hndl *samplingHandler) sweepAndReport(all bool) {
+	hndl.lock.Lock()
+	hndl.unlockAndOutput(hndl.sweepLocked(nil, all))
+}
+
+func (hndl *samplingHandler) sweep() {
+	hndl.sweepAndReport(false)
+}
+
+func (hndl *samplingHandler) Flush() {
+	hndl.sweepAndReport(true)
+	hndl.base.Flush()
+}
+
+// 間引いた数を書き出して、base を Flush する。
+// base は閉じない。
+func (hndl *samplingHandler) Close() error {
+	hndl.lock.Lock()
+	if hndl.closed {
+		hndl.lock.Unlock()
+		return nil
+	}
+	hndl.closed = true
+	recs := hndl.sweepLocked(nil, true)
+	hndl.counters = map[samplingKey]*samplingCounter{}
+	if hndl.timer != nil {
+		hndl.timer.Stop()
+		hndl.timer = nil
+	}
+	hndl.unlockAndOutput(recs)
+
+	hndl.base.Flush()
+	return nil
+}
@@ -0,0 +1,220 @@
+// Copyright 2015 realglobe, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package handler
+
+import (
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/realglobe-Inc/go-lib/rglog/level"
+)
+
+func newTestSamplingHandler(t *testing.T, base Handler, conf SamplingConfig) *samplingHandler {
+	hndl, err := NewSamplingHandler(base, conf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return hndl.(*samplingHandler)
+}
+
+func TestSamplingHandlerLevel(t *testing.T) {
+	testHandlerLevel(t, newTestSamplingHandler(t, NewNopHandler(), SamplingConfig{Interval: time.Second, First: 1}))
+}
+
+func TestSamplingHandlerOutput(t *testing.T) {
+	testHandlerOutput(t, newTestSamplingHandler(t, NewMemoryHandler(), SamplingConfig{Interval: time.Second, First: 1}))
+}
+
+func TestSamplingHandlerKeepBase(t *testing.T) {
+	testHandlerKeepBase(t, func(base Handler) Handler {
+		return newTestSamplingHandler(t, base, SamplingConfig{Interval: time.Hour, First: 1})
+	})
+}
+
+func TestSamplingHandlerInvalidInterval(t *testing.T) {
+	for _, interval := range []time.Duration{0, -time.Second} {
+		if _, err := NewSamplingHandler(NewNopHandler(), SamplingConfig{Interval: interval, First: 1}); err == nil {
+			t.Error(interval)
+		}
+	}
+}
+
+func TestSamplingHandlerCallSite(t *testing.T) {
+	base := NewMemoryHandlerUsing(LevelOnlyFormatter)
+	hndl := newTestSamplingHandler(t, base, SamplingConfig{Interval: time.Hour, First: 2, Thereafter: 3})
+
+	for i := 0; i < 10; i++ {
+		hndl.Output(&record{date: time.Now(), lv: level.ERR, file: "a.go", line: 1, msg: "a"})
+		hndl.Output(&record{date: time.Now(), lv: level.ERR, file: "a.go", line: 2, msg: "b"})
+	}
+	// 1, 2, 5, 8 番目だけ。
+	if dump := base.Dump(); strings.Count(dump, "[ERR] a\n") != 4 || strings.Count(dump, "[ERR] b\n") != 4 {
+		t.Fatal(dump)
+	}
+
+	hndl.Flush()
+	if dump := base.Dump(); !strings.Contains(dump, "[ERR] 6 similar records were suppressed: a suppressed=6\n") ||
+		!strings.Contains(dump, "[ERR] 6 similar records were suppressed: b suppressed=6\n") {
+		t.Fatal(dump)
+	}
+}
+
+func TestSamplingHandlerMessage(t *testing.T) {
+	base := NewMemoryHandlerUsing(LevelOnlyFormatter)
+	hndl := newTestSamplingHandler(t, base, SamplingConfig{Interval: time.Hour, First: 1, Key: SampleByMessage})
+
+	hndl.Output(&record{date: time.Now(), lv: level.ERR, file: "a.go", line: 1, msg: "a"})
+	hndl.Output(&record{date: time.Now(), lv: level.ERR, file: "a.go", line: 2, msg: "a"})
+	hndl.Output(&record{date: time.Now(), lv: level.ERR, file: "a.go", line: 2, msg: "b"})
+	if dump := base.Dump(); dump != "[ERR] a\n[ERR] b\n" {
+		t.Fatal(dump)
+	}
+}
+
+func TestSamplingHandlerInterval(t *testing.T) {
+	base := NewMemoryHandlerUsing(LevelOnlyFormatter)
+	hndl := newTestSamplingHandler(t, base, SamplingConfig{Interval: 20 * time.Millisecond, First: 1})
+
+	hndl.Output(&record{date: time.Now(), lv: level.ERR, file: "a.go", line: 1, msg: "a"})
+	hndl.Output(&record{date: time.Now(), lv: level.ERR, file: "a.go", line: 1, msg: "a"})
+	// 何も書き出さなくても、間引いた数が書き出される。
+	for i := 0; !strings.Contains(base.Dump(), "suppressed=1"); i++ {
+		if i > 100 {
+			t.Fatal(base.Dump())
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	hndl.Output(&record{date: time.Now(), lv: level.ERR, file: "a.go", line: 1, msg: "a"})
+	if dump := base.Dump(); dump != "[ERR] a\n[ERR] 1 similar records were suppressed: a suppressed=1\n[ERR] a\n" {
+		t.Fatal(dump)
+	}
+	hndl.Close()
+}
+
+// 間引かなくても、数え終わったものは片付けられるか。
+func TestSamplingHandlerSweep(t *testing.T) {
+	hndl := newTestSamplingHandler(t, NewNopHandler(), SamplingConfig{Interval: 20 * time.Millisecond, First: 1})
+	defer hndl.Close()
+
+	hndl.Output(&record{date: time.Now(), lv: level.ERR, file: "a.go", line: 1, msg: "a"})
+	for i := 0; ; i++ {
+		hndl.lock.Lock()
+		n := len(hndl.counters)
+		hndl.lock.Unlock()
+		if n == 0 {
+			break
+		} else if i > 100 {
+			t.Fatal(n)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+// 数える単位の数が上限を超えないか。
+func TestSamplingHandlerMaxCounters(t *testing.T) {
+	base := NewMemoryHandlerUsing(LevelOnlyFormatter)
+	hndl := newTestSamplingHandler(t, base, SamplingConfig{Interval: time.Hour, First: 1})
+	defer hndl.Close()
+
+	for i := 0; i < maxSamplingCounters+10; i++ {
+		hndl.Output(&record{date: time.Now(), lv: level.ERR, file: "a.go", line: i, msg: "a"})
+	}
+	if n := len(hndl.counters); n != maxSamplingCounters {
+		t.Fatal(n)
+	}
+	// 上限を超えた分は間引かない。
+	line := maxSamplingCounters + 5
+	hndl.Output(&record{date: time.Now(), lv: level.ERR, file: "a.go", line: line, msg: "a"})
+	if n := strings.Count(base.Dump(), "[ERR] a\n"); n != maxSamplingCounters+11 {
+		t.Fatal(n)
+	}
+}
+
+// 書き出し中にロックを持っていないか。
+func TestSamplingHandlerOutputUnlocked(t *testing.T) {
+	var hndl *samplingHandler
+	base := &outputFuncHandler{NewNopHandler(), func(rec Record) {
+		// ロックを持っていたら止まる。
+		hndl.lock.Lock()
+		hndl.lock.Unlock()
+	}}
+	hndl = newTestSamplingHandler(t, base, SamplingConfig{Interval: time.Hour, First: 1})
+	hndl.Output(&record{date: time.Now(), lv: level.ERR, file: "a.go", line: 1, msg: "a"})
+	hndl.Output(&record{date: time.Now(), lv: level.ERR, file: "a.go", line: 1, msg: "a"})
+	hndl.Flush()
+	hndl.Close()
+}
+
+// 間引いた数のログが、それが数えたログより先に書き出されないか。
+func TestSamplingHandlerOutputOrder(t *testing.T) {
+	var lock sync.Mutex
+	msgs := []string{}
+	block := make(chan struct{})
+	base := &outputFuncHandler{NewNopHandler(), func(rec Record) {
+		if rec.Message() == "a" {
+			// 書き出し中に他で数える。
+			<-block
+		}
+		lock.Lock()
+		msgs = append(msgs, rec.Message())
+		lock.Unlock()
+	}}
+	hndl := newTestSamplingHandler(t, base, SamplingConfig{Interval: time.Hour, First: 1})
+
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		hndl.Output(&record{date: time.Now(), lv: level.ERR, file: "a.go", line: 1, msg: "a"})
+	}()
+	// a が書き出し中になるまで待つ。
+	for i := 0; ; i++ {
+		hndl.lock.Lock()
+		n := len(hndl.counters)
+		hndl.lock.Unlock()
+		if n > 0 {
+			break
+		} else if i > 100 {
+			t.Fatal(n)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	hndl.Output(&record{date: time.Now(), lv: level.ERR, file: "a.go", line: 1, msg: "b"})
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		hndl.Flush()
+	}()
+	time.Sleep(20 * time.Millisecond)
+	close(block)
+	wg.Wait()
+
+	if len(msgs) != 2 || msgs[0] != "a" || msgs[1] != "1 similar records were suppressed: b" {
+		t.Fatal(msgs)
+	}
+}
+
+// Output だけ差し替えたハンドラ。
+type outputFuncHandler struct {
+	Handler
+	output func(rec Record)
+}
+
+func (hndl *outputFuncHandler) Output(rec Record) {
+	hndl.output(rec)
+}
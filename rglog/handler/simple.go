@@ -34,6 +34,8 @@ type basicHandler struct {
 	fmter Formatter
 
 	sink io.Writer
+	// 閉じたら書き出さない。
+	closed bool
 }
 
 func NewBasicHandler(sink io.Writer) Handler {
@@ -66,7 +68,7 @@ func (hndl *basicHandler) Output(rec Record) {
 	hndl.lock.Lock()
 	defer hndl.lock.Unlock()
 
-	if !rec.Level().Lower(hndl.lv) {
+	if !hndl.closed && !rec.Level().Lower(hndl.lv) {
 		hndl.sink.Write(hndl.fmter.Format(rec))
 	}
 }
@@ -75,8 +77,12 @@ func (hndl *basicHandler) Flush() {
 	return
 }
 
-func (hndl *basicHandler) Close() {
-	return
+func (hndl *basicHandler) Close() error {
+	hndl.lock.Lock()
+	defer hndl.lock.Unlock()
+
+	hndl.closed = true
+	return nil
 }
 
 // 与えられた出力先にバッファを挟んで書き出すだけの Handler.
@@ -116,20 +122,26 @@ type closeHandler struct {
 	closer io.Closer
 }
 
-func (hndl *closeHandler) Close() {
+func (hndl *closeHandler) Close() error {
 	hndl.lock.Lock()
 	defer hndl.lock.Unlock()
 
-	hndl.Flush()
+	if hndl.closed {
+		return nil
+	}
+	hndl.closed = true
 
+	flushErr := hndl.flusher.Flush()
 	if err := hndl.closer.Close(); err != nil {
-		err = erro.Wrap(err)
-		fmt.Fprintln(os.Stderr, err)
+		return erro.Wrap(err)
+	} else if flushErr != nil {
+		return erro.Wrap(flushErr)
 	}
+	return nil
 }
 
 func NewCloseHandler(sink io.WriteCloser) Handler {
-	return NewFlushHandlerUsing(sink, SimpleFormatter)
+	return NewCloseHandlerUsing(sink, SimpleFormatter)
 }
 
 func NewCloseHandlerUsing(sink io.WriteCloser, fmter Formatter) Handler {
@@ -154,7 +166,7 @@ func NewFileHandlerUsing(path string, fmter Formatter) (Handler, error) {
 	if err := os.MkdirAll(filepath.Dir(path), dirPerm); err != nil {
 		return nil, erro.Wrap(err)
 	}
-	// file の Close はプログラムの終処理任せ。
+	// Close しなければ、file の Close はプログラムの終処理任せ。
 	sink, err := os.OpenFile(path, os.O_RDWR|os.O_APPEND|os.O_CREATE, filePerm)
 	if err != nil {
 		return nil, erro.Wrap(err)
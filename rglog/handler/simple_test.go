@@ -16,7 +16,12 @@ package handler
 
 import (
 	"io/ioutil"
+	"os"
+	"strings"
 	"testing"
+	"time"
+
+	"github.com/realglobe-Inc/go-lib/rglog/level"
 )
 
 func TestBasicHandlerLevel(t *testing.T) {
@@ -42,3 +47,24 @@ func TestFlushHandlerOutput(t *testing.T) {
 func BenchmarkFlushHandler(b *testing.B) {
 	benchmarkHandler(b, NewFlushHandler(ioutil.Discard))
 }
+
+func TestFileHandlerClose(t *testing.T) {
+	path, err := testFilePath()
+	if err != nil {
+		t.Fatal(err)
+	}
+	hndl, err := NewFileHandler(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.Remove(path)
+
+	hndl.Output(&record{date: time.Now(), lv: level.INFO, file: "test", msg: "a"})
+	testHandlerOutputAfterClose(t, hndl)
+
+	if buff, err := ioutil.ReadFile(path); err != nil {
+		t.Fatal(err)
+	} else if strings.Count(string(buff), "\n") != 1 {
+		t.Fatal(string(buff))
+	}
+}
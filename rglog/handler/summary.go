@@ -0,0 +1,46 @@
+// Copyright 2015 realglobe, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package handler
+
+import (
+	"time"
+)
+
+// 元のログの重要度や呼び出し元を引き継いで、日時、メッセージ、付加情報だけ差し替えたログ。
+// 間引きや重複をまとめたことを知らせるのに使う。
+type summaryRecord struct {
+	Record
+	date   time.Time
+	msg    string
+	fields []Field
+}
+
+// rec のメッセージを msg にして、付加情報 field を足したログをつくる。
+func newSummaryRecord(rec Record, msg string, field Field) Record {
+	fields := append(append([]Field{}, rec.Fields()...), field)
+	return &summaryRecord{rec, time.Now(), msg, fields}
+}
+
+func (rec *summaryRecord) Date() time.Time {
+	return rec.date
+}
+
+func (rec *summaryRecord) Message() string {
+	return rec.msg
+}
+
+func (rec *summaryRecord) Fields() []Field {
+	return rec.fields
+}
@@ -18,7 +18,6 @@ import (
 	"fmt"
 	"log/syslog"
 	"os"
-	"strconv"
 
 	"github.com/realglobe-Inc/go-lib/erro"
 	"github.com/realglobe-Inc/go-lib/rglog/level"
@@ -27,17 +26,15 @@ import (
 // syslog にログを流す coreHandler。
 // ログデーモンが一時的に落ちていても、動き出せば元通りに動く。
 type syslogCoreHandler struct {
-	tag  string
-	addr string
+	tag   string
+	addr  string
+	fmter Formatter
 
 	base *syslog.Writer
 }
 
 func (core *syslogCoreHandler) output(rec Record) {
-	// {レベル} {ファイル名}:{行番号} {メッセージ}
-	// 日時は syslog が付ける。
-	msg := fmt.Sprintf("%."+strconv.Itoa(lvWidth)+"v %s:%d %s\n",
-		rec.Level(), rec.File(), rec.Line(), rec.Message())
+	msg := string(core.fmter.Format(rec))
 
 	for retry := false; ; retry = true {
 		if core.base == nil {
@@ -55,13 +52,20 @@ func (core *syslogCoreHandler) output(rec Record) {
 
 		var err error
 		switch rec.Level() {
+		case level.FATAL:
+			// LOG_EMERG は端末全部に表示されたりするので使わない。
+			err = core.base.Alert(msg)
+		case level.CRIT:
+			err = core.base.Crit(msg)
 		case level.ERR:
 			err = core.base.Err(msg)
 		case level.WARN:
 			err = core.base.Warning(msg)
+		case level.NOTICE:
+			err = core.base.Notice(msg)
 		case level.INFO:
 			err = core.base.Info(msg)
-		case level.DEBUG:
+		case level.DEBUG, level.TRACE:
 			err = core.base.Debug(msg)
 		}
 		if err == nil {
@@ -88,13 +92,19 @@ func (core *syslogCoreHandler) flush() {
 	return
 }
 
-func (core *syslogCoreHandler) close() {
+func (core *syslogCoreHandler) close() error {
+	if core.base == nil {
+		// 一度も書き込んでいないか、書き込みに失敗して閉じてある。
+		return nil
+	}
 	core.flush()
 
-	if err := core.base.Close(); err != nil {
-		err = erro.Wrap(err)
-		fmt.Fprintln(os.Stderr, err)
+	err := core.base.Close()
+	core.base = nil
+	if err != nil {
+		return erro.Wrap(err)
 	}
+	return nil
 }
 
 func NewSyslogHandler(tag string) Handler {
@@ -102,5 +112,15 @@ func NewSyslogHandler(tag string) Handler {
 }
 
 func NewSyslogHandlerTo(addr, tag string) Handler {
-	return wrapCoreHandler(newSynchronizedCoreHandler(&syslogCoreHandler{tag: tag, addr: addr}))
+	return NewSyslogHandlerToUsing(addr, tag, syslogFormatter)
+}
+
+// 日時、ホスト名、プロセス ID は syslog が付けるので、fmter はそれらを含まないものが良い。
+// ロガー名等を含めたいなら &DetailFormatter{Logger: true} 等を使う。
+func NewSyslogHandlerToUsing(addr, tag string, fmter Formatter) Handler {
+	return wrapCoreHandler(newSynchronizedCoreHandler(&syslogCoreHandler{tag: tag, addr: addr, fmter: fmter}))
 }
+
+// {レベル} {ファイル名}:{行番号} {メッセージ} {キー}={値}...
+// 日時は syslog が付ける。
+var syslogFormatter = &DetailFormatter{}
@@ -55,6 +55,17 @@ func TestSyslogHandlerOutput(t *testing.T) {
 	testHandlerOutput(t, NewSyslogHandler("go-lib"))
 }
 
+// 一度も書き込んでいなくても閉じられるか。
+// サーバーが無くても試せる。
+func TestSyslogHandlerCloseUnused(t *testing.T) {
+	if err := NewSyslogHandler("go-lib").Close(); err != nil {
+		t.Fatal(err)
+	}
+	if err := (&syslogCoreHandler{}).close(); err != nil {
+		t.Fatal(err)
+	}
+}
+
 // TODO 複数のコネクションで大量にログを吐くとデッドロックする場合がある。対処法不明。
 func TestManySyslogHandler(t *testing.T) {
 	if !testSyslogHandlerFlag {
@@ -15,6 +15,10 @@
 package level
 
 import (
+	"encoding/json"
+	"strconv"
+	"strings"
+
 	"github.com/realglobe-Inc/go-lib/erro"
 )
 
@@ -24,30 +28,51 @@ const (
 	// 表示レベル無指定 0 なら OFF になるので、何も出力しない。
 	OFF Level = iota
 
-	ERR
-	WARN
-	INFO
-	DEBUG
+	// 以下、syslog の重要度に合わせてある。
+	// FATAL, CRIT, NOTICE, TRACE を足したときに、ERR 以降の数値が変わっている。
+	// 数値ではなく名前で保存すること。
+	FATAL  // 動作を継続できない。
+	CRIT   // 緊急の対処が必要。
+	ERR    // エラー。
+	WARN   // 警告。
+	NOTICE // 正常だが注意すべき。
+	INFO   // 情報。
+	DEBUG  // デバッグ用。
+	TRACE  // DEBUG より細かい追跡用。
 
 	ALL
 )
 
 var lvToLabel []string = []string{
-	OFF:   "OFF",
-	ERR:   "ERR",
-	WARN:  "WARN",
-	INFO:  "INFO",
-	DEBUG: "DEBUG",
-	ALL:   "ALL",
+	OFF:    "OFF",
+	FATAL:  "FATAL",
+	CRIT:   "CRIT",
+	ERR:    "ERR",
+	WARN:   "WARN",
+	NOTICE: "NOTICE",
+	INFO:   "INFO",
+	DEBUG:  "DEBUG",
+	TRACE:  "TRACE",
+	ALL:    "ALL",
 }
 
 var labelToLv map[string]Level
 
+// 他でよく使われる名前。
+var aliasToLv = map[string]Level{
+	"CRITICAL": CRIT,
+	"ERROR":    ERR,
+	"WARNING":  WARN,
+}
+
 func init() {
 	labelToLv = make(map[string]Level)
 	for lv, label := range lvToLabel {
 		labelToLv[label] = Level(lv)
 	}
+	for label, lv := range aliasToLv {
+		labelToLv[label] = lv
+	}
 }
 
 // lv が lv2 より重要なときのみ true。
@@ -69,15 +94,72 @@ func (lv Level) String() string {
 }
 
 // 文字列から値に。
+// 大文字小文字は区別しない。
+// ERROR, WARNING 等の別名や、"3" のような数値も受け付ける。
 func ValueOf(label string) (Level, error) {
-	lv, ok := labelToLv[label]
+	lv, ok := labelToLv[strings.ToUpper(strings.TrimSpace(label))]
 	if ok {
 		return lv, nil
+	} else if val, err := strconv.Atoi(strings.TrimSpace(label)); err == nil && int(OFF) <= val && val <= int(ALL) {
+		return Level(val), nil
 	} else {
 		return 0, erro.New("level " + label + " is not exist")
 	}
 }
 
+// encoding.TextMarshaler を実装。
+func (lv Level) MarshalText() ([]byte, error) {
+	val := int(lv)
+	if val < 0 || len(lvToLabel) <= val {
+		return nil, erro.New("level " + strconv.Itoa(val) + " is not exist")
+	}
+	return []byte(lvToLabel[val]), nil
+}
+
+// encoding.TextUnmarshaler を実装。
+func (lv *Level) UnmarshalText(text []byte) error {
+	val, err := ValueOf(string(text))
+	if err != nil {
+		return erro.Wrap(err)
+	}
+	*lv = val
+	return nil
+}
+
+// json.Marshaler を実装。
+// 文字列にする。
+func (lv Level) MarshalJSON() ([]byte, error) {
+	text, err := lv.MarshalText()
+	if err != nil {
+		return nil, erro.Wrap(err)
+	}
+	return json.Marshal(string(text))
+}
+
+// json.Unmarshaler を実装。
+// 文字列の他に数値も受け付ける。
+// null なら何もしない。
+func (lv *Level) UnmarshalJSON(data []byte) error {
+	if string(data) == "null" {
+		return nil
+	}
+	var label string
+	if len(data) > 0 && data[0] == '"' {
+		if err := json.Unmarshal(data, &label); err != nil {
+			return erro.Wrap(err)
+		}
+	} else {
+		label = string(data)
+	}
+	return lv.UnmarshalText([]byte(label))
+}
+
+// flag.Value を実装。
+// flags.Var(&param, "level", "Log level") の形でも使える。
+func (lv *Level) Set(s string) error {
+	return lv.UnmarshalText([]byte(s))
+}
+
 // 重要度降順で列挙する。
 func Values() []Level {
 	values := []Level{}
@@ -93,12 +175,15 @@ type levelVar struct {
 }
 
 func (v levelVar) Set(s string) error {
-	var err error
-	*v.Level, err = ValueOf(s)
-	if err != nil {
-		return erro.Wrap(err)
+	return v.Level.Set(s)
+}
+
+// flag.PrintDefaults はゼロ値でも呼ぶので、nil に対応しておく。
+func (v levelVar) String() string {
+	if v.Level == nil {
+		return ""
 	}
-	return nil
+	return v.Level.String()
 }
 
 // flags.Var(Var(&param, level.INFO), "level", "Log level") の形で使う。
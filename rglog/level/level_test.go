@@ -15,7 +15,10 @@
 package level
 
 import (
+	"encoding/json"
 	"flag"
+	"io/ioutil"
+	"log/slog"
 	"testing"
 )
 
@@ -67,3 +70,144 @@ func TestVar(t *testing.T) {
 		}
 	}
 }
+
+// syslog の重要度の順になっているか。
+func TestSyslogOrder(t *testing.T) {
+	lvs := []Level{FATAL, CRIT, ERR, WARN, NOTICE, INFO, DEBUG, TRACE}
+	for i := 1; i < len(lvs); i++ {
+		if !lvs[i-1].Higher(lvs[i]) {
+			t.Fatal(lvs[i-1], lvs[i])
+		}
+	}
+	if !OFF.Higher(FATAL) {
+		t.Fatal(OFF, FATAL)
+	} else if !TRACE.Higher(ALL) {
+		t.Fatal(TRACE, ALL)
+	}
+}
+
+func TestValueOfVariants(t *testing.T) {
+	for label, lv := range map[string]Level{
+		"debug":    DEBUG,
+		"Info":     INFO,
+		" WARN ":   WARN,
+		"warning":  WARN,
+		"ERROR":    ERR,
+		"critical": CRIT,
+		"trace":    TRACE,
+		"0":        OFF,
+		"3":        ERR,
+	} {
+		if lv2, err := ValueOf(label); err != nil {
+			t.Fatal(label, err)
+		} else if lv2 != lv {
+			t.Fatal(label, lv2, lv)
+		}
+	}
+
+	for _, label := range []string{"", "unko", "-1", "100"} {
+		if lv, err := ValueOf(label); err == nil {
+			t.Fatal(label, lv)
+		}
+	}
+}
+
+func TestText(t *testing.T) {
+	for _, lv := range Values() {
+		text, err := lv.MarshalText()
+		if err != nil {
+			t.Fatal(lv, err)
+		}
+		var lv2 Level
+		if err := lv2.UnmarshalText(text); err != nil {
+			t.Fatal(lv, err)
+		} else if lv2 != lv {
+			t.Fatal(lv2, lv)
+		}
+	}
+
+	if _, err := Level(-1).MarshalText(); err == nil {
+		t.Fatal("no error")
+	}
+}
+
+func TestJSON(t *testing.T) {
+	type config struct {
+		Level Level `json:"level"`
+	}
+
+	for _, lv := range Values() {
+		data, err := json.Marshal(&config{lv})
+		if err != nil {
+			t.Fatal(lv, err)
+		} else if string(data) != `{"level":"`+lv.String()+`"}` {
+			t.Fatal(string(data))
+		}
+		var conf config
+		if err := json.Unmarshal(data, &conf); err != nil {
+			t.Fatal(lv, err)
+		} else if conf.Level != lv {
+			t.Fatal(conf.Level, lv)
+		}
+	}
+
+	var conf config
+	if err := json.Unmarshal([]byte(`{"level":7}`), &conf); err != nil {
+		t.Fatal(err)
+	} else if conf.Level != DEBUG {
+		t.Fatal(conf.Level)
+	}
+
+	// null は何もしない。
+	conf = config{WARN}
+	if err := json.Unmarshal([]byte(`{"level":null}`), &conf); err != nil {
+		t.Fatal(err)
+	} else if conf.Level != WARN {
+		t.Fatal(conf.Level)
+	}
+
+	if err := json.Unmarshal([]byte(`{"level":"unko"}`), &conf); err == nil {
+		t.Fatal("no error")
+	}
+}
+
+func TestFlagValue(t *testing.T) {
+	flags := flag.NewFlagSet("test", flag.ContinueOnError)
+	flags.SetOutput(ioutil.Discard)
+	lv := INFO
+	flags.Var(&lv, "lv", "Log level")
+	var lv2 Level
+	flags.Var(Var(&lv2, INFO), "lv2", "Log level")
+	if err := flags.Parse([]string{"-lv", "debug", "-lv2", "warning"}); err != nil {
+		t.Fatal(err)
+	} else if lv != DEBUG {
+		t.Fatal(lv)
+	} else if lv2 != WARN {
+		t.Fatal(lv2)
+	}
+
+	// ゼロ値でも String が使えないと flag.PrintDefaults で困る。
+	if s := (levelVar{}).String(); s != "" {
+		t.Fatal(s)
+	}
+	flags.PrintDefaults()
+}
+
+func TestFromSlog(t *testing.T) {
+	for _, c := range []struct {
+		slogLv slog.Level
+		lv     Level
+	}{
+		{slog.LevelDebug - 1, TRACE},
+		{slog.LevelDebug, DEBUG},
+		{slog.LevelInfo, INFO},
+		{slog.LevelInfo + 2, NOTICE},
+		{slog.LevelWarn, WARN},
+		{slog.LevelError, ERR},
+		{slog.LevelError + 4, CRIT},
+	} {
+		if lv := FromSlog(c.slogLv); lv != c.lv {
+			t.Error(c.slogLv, lv, c.lv)
+		}
+	}
+}
@@ -0,0 +1,41 @@
+// Copyright 2015 realglobe, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package level
+
+import (
+	"log/slog"
+)
+
+// slog の重要度を変換する。
+// slog.LevelDebug は DEBUG、slog.LevelInfo は INFO、slog.LevelWarn は WARN、slog.LevelError は ERR になる。
+// slog.LevelInfo+2 以上 slog.LevelWarn 未満は NOTICE、slog.LevelError+4 以上は CRIT、slog.LevelDebug 未満は TRACE になる。
+func FromSlog(lv slog.Level) Level {
+	switch {
+	case lv >= slog.LevelError+4:
+		return CRIT
+	case lv >= slog.LevelError:
+		return ERR
+	case lv >= slog.LevelWarn:
+		return WARN
+	case lv >= slog.LevelInfo+2:
+		return NOTICE
+	case lv >= slog.LevelInfo:
+		return INFO
+	case lv >= slog.LevelDebug:
+		return DEBUG
+	default:
+		return TRACE
+	}
+}
@@ -15,6 +15,15 @@
 package rglog
 
 import (
+	"context"
+	"io"
+	stdlog "log"
+	"log/slog"
+	"net/http"
+	"sync"
+
+	"github.com/realglobe-Inc/go-lib/erro"
+	"github.com/realglobe-Inc/go-lib/rglog/admin"
 	"github.com/realglobe-Inc/go-lib/rglog/handler"
 	"github.com/realglobe-Inc/go-lib/rglog/level"
 	"github.com/realglobe-Inc/go-lib/rglog/logger"
@@ -24,29 +33,130 @@ import (
 // handler 書き出し機。
 // logger ハンドラをまとめたり、親子関係をつくったり。
 
-var mgr logger.Manager
+// 付加情報をつくる。
+// log.Info("Log message", rglog.F("id", id)) のように使う。
+func F(key string, val interface{}) handler.Field {
+	return handler.Field{Key: key, Value: val}
+}
 
-func init() {
-	mgr = logger.NewLockLoggerManager()
+// ロガーを入れた context をつくる。
+func NewContext(ctx context.Context, log logger.Logger) context.Context {
+	return logger.NewContext(ctx, log)
 }
 
-// 無設定時の動作を設定。
-func init() {
-	log := mgr.Logger("")
-	log.SetLevel(level.INFO)
-	log.SetUseParent(false)
+// context に入っているロガーを返す。
+// 入っていないか、ctx が nil なら "" のロガーを返す。
+func FromContext(ctx context.Context) logger.Logger {
+	if log := logger.FromContext(ctx); log != nil {
+		return log
+	}
+	return Logger("")
+}
 
-	hndl := handler.NewConsoleHandler()
-	hndl.SetLevel(level.ALL)
-	log.AddHandler("console", hndl)
+// リクエスト ID 等の付加情報を足した context をつくる。
+// kv は logger.Logger.With と同じ形式。
+// InfoContext 等でログを取ると付加情報が付く。
+func WithFields(ctx context.Context, kv ...interface{}) context.Context {
+	return logger.ContextWithFields(ctx, kv...)
 }
 
 // 各パッケージの init で 1 回だけ呼ぶくらいを想定。
+// 大域の Manager のロガーを返す。
+// 返したロガーは呼ばれる度に大域の Manager を引くので、後で SetManager すれば新しい Manager のロガーになる。
+// 特定の Manager のロガーは、その Manager の Logger で取得する。
+// 同じ name には同じロガーを返す。
 func Logger(name string) logger.Logger {
-	return mgr.Logger(name)
+	if log, ok := indirectLoggers.Load(name); ok {
+		return log.(logger.Logger)
+	}
+	log, _ := indirectLoggers.LoadOrStore(name, logger.NewIndirectLogger(name, Manager))
+	return log.(logger.Logger)
+}
+
+// Logger で返したロガー。
+var indirectLoggers sync.Map
+
+// "a/b=DEBUG,c=WARN" のような指定で、ロガーごとの重要度をまとめて設定する。
+// 書式は logger.ParseLevelSpec を参照。
+func SetLevelSpec(spec string) error {
+	s, err := logger.ParseLevelSpec(spec)
+	if err != nil {
+		return erro.Wrap(err)
+	}
+	s.Apply(Manager())
+	return nil
+}
+
+// "handler/rotate*.go=DEBUG" のような指定で、ソースファイルごとの重要度の下限を設定する。
+// 前の指定は捨てる。空文字列なら解除する。
+// 書式は logger.VModule を参照。
+// 大域の Manager が logger.VModuleManager でなければエラーを返す。
+func SetVModule(spec string) error {
+	vmod, err := logger.ParseVModule(spec)
+	if err != nil {
+		return erro.Wrap(err)
+	}
+	mgr, ok := Manager().(logger.VModuleManager)
+	if !ok {
+		return erro.New("manager does not support vmodule")
+	}
+	mgr.SetVModule(vmod)
+	return nil
+}
+
+// 標準の log パッケージの出力を log に lv で記録するようにする。
+// 呼び出し元を取れるように、標準の log のフラグは log.Llongfile だけにする。
+// 返り値の関数で元に戻す。
+func RedirectStdLog(log logger.Logger, lv level.Level) (restore func()) {
+	w, flags := stdlog.Writer(), stdlog.Flags()
+	stdlog.SetOutput(logger.NewWriter(log, lv))
+	stdlog.SetFlags(stdlog.Llongfile)
+	return func() {
+		stdlog.SetOutput(w)
+		stdlog.SetFlags(flags)
+	}
+}
+
+// log に記録する slog.Handler を返す。
+// slog.New(rglog.SlogHandler(log)) のように使う。
+func SlogHandler(log logger.Logger) slog.Handler {
+	return logger.NewSlogHandler(log)
+}
+
+// hndl に直接書き出す slog.Handler を返す。
+func SlogHandlerTo(hndl handler.Handler) slog.Handler {
+	return logger.NewSlogHandlerTo(hndl)
+}
+
+// name のロガーに記録する slog.Logger を返す。
+// 書き出すハンドラや重要度は name のロガーとその先祖の設定に従う。
+func SlogLogger(name string) *slog.Logger {
+	return slog.New(logger.NewSlogHandler(Logger(name)))
+}
+
+// ロガーの設定を実行中に見たり変えたりするための http.Handler を返す。
+// 詳細は admin パッケージを参照。
+func AdminHandler() http.Handler {
+	return admin.NewHandler(Manager())
+}
+
+// AdminHandler を Unix ドメインソケット path で提供する。
+// rglogctl コマンドで操作できる。
+// 返り値を Close すると止まる。
+func ServeAdmin(path string) (io.Closer, error) {
+	return admin.ServeUnix(path, AdminHandler())
+}
+
+// 全てのハンドラを Flush して Close する。
+// プログラムの終わりに呼ぶ。
+// ctx が終わったら、終わっていない処理を待たずに返る。
+// 大域の Manager が logger.ClosableManager でなければ Flush だけする。
+func Close(ctx context.Context) error {
+	return logger.CloseManager(ctx, Manager())
 }
 
-// TODO 手動で Flush しなくちゃならないのは面倒。終処理にフックしたい。
+// 全てのハンドラを Flush する。
+// 終了時には Shutdown, InstallShutdownHooks, NotifyShutdown, RecoverAndFlush も使える。
 func Flush() {
-	mgr.Flush()
+	Manager().Flush()
 }
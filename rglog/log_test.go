@@ -0,0 +1,57 @@
+// Copyright 2015 realglobe, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package rglog
+
+import (
+	stdlog "log"
+	"strings"
+	"testing"
+
+	"github.com/realglobe-Inc/go-lib/rglog/handler"
+	"github.com/realglobe-Inc/go-lib/rglog/level"
+	"github.com/realglobe-Inc/go-lib/rglog/logger"
+)
+
+func TestRedirectStdLog(t *testing.T) {
+	log := logger.NewLockLoggerManager().Logger("a")
+	log.SetLevel(level.INFO)
+	hndl := handler.NewMemoryHandlerUsing(handler.LevelOnlyFormatter)
+	log.AddHandler("test", hndl)
+
+	flags := stdlog.Flags()
+	restore := RedirectStdLog(log, level.WARN)
+	stdlog.Print("abc")
+	restore()
+
+	if dump := hndl.Dump(); !strings.HasPrefix(dump, "[WAR] abc") {
+		t.Fatal(dump)
+	} else if stdlog.Flags() != flags {
+		t.Fatal(stdlog.Flags(), flags)
+	}
+}
+
+func TestFromContextNil(t *testing.T) {
+	if log := FromContext(nil); log != Logger("") {
+		t.Fatal(log)
+	}
+}
+
+func TestLoggerSame(t *testing.T) {
+	if Logger("a") != Logger("a") {
+		t.Fatal("different loggers")
+	} else if Logger("a") == Logger("b") {
+		t.Fatal("same logger")
+	}
+}
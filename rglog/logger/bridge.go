@@ -0,0 +1,216 @@
+// Copyright 2015 realglobe, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package logger
+
+import (
+	"context"
+	"io"
+	"log/slog"
+	"runtime"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/realglobe-Inc/go-lib/rglog/handler"
+	"github.com/realglobe-Inc/go-lib/rglog/level"
+)
+
+// 呼び出し元等を決めてあるログを取れるロガー。
+// lockLogger 以外の Logger では、呼び出し元が正しく取れない。
+type recordLogger interface {
+	// 呼び出し元によってはハンドラに渡るかどうか。
+	// ソースファイルごとの重要度の下限は、呼び出し元がわかるまで決まらないので。
+	mayLog(lv level.Level) bool
+	logRecord(ctx context.Context, rec *record)
+}
+
+type writer struct {
+	log Logger
+	lv  level.Level
+}
+
+// 標準の log パッケージの出力を log に lv で記録する io.Writer をつくる。
+// 1 回の Write を 1 つのログとして扱う。
+// log.Llongfile か log.Lshortfile を付けておくと、先頭の "{ファイル}:{行}: " を呼び出し元として使う。
+// 日時は Write された時刻にするので、log.LstdFlags は付けない方が良い。
+func NewWriter(log Logger, lv level.Level) io.Writer {
+	return &writer{log, lv}
+}
+
+func (w *writer) Write(p []byte) (int, error) {
+	recLog, ok := w.log.(recordLogger)
+	if ok && !recLog.mayLog(w.lv) || !ok && !w.log.IsLoggable(w.lv) {
+		return len(p), nil
+	}
+
+	line := strings.TrimSuffix(string(p), "\n")
+	file, lineNum, msg := parseStdLogLine(line)
+	if ok {
+		rec := &record{
+			date: time.Now(),
+			lv:   w.lv,
+			file: file,
+			line: lineNum,
+			msg:  msg,
+		}
+		recLog.logRecord(context.Background(), rec)
+	} else {
+		w.log.Log(w.lv, msg)
+	}
+	return len(p), nil
+}
+
+// "{ファイル}:{行}: {メッセージ}" を分ける。
+// 呼び出し元が無ければ "???" と 0 を返す。
+func parseStdLogLine(line string) (file string, lineNum int, msg string) {
+	pos := strings.Index(line, ".go:")
+	if pos < 0 {
+		return "???", 0, line
+	}
+	rest := line[pos+len(".go:"):]
+	end := strings.Index(rest, ": ")
+	if end < 0 {
+		return "???", 0, line
+	}
+	n, err := strconv.Atoi(rest[:end])
+	if err != nil {
+		return "???", 0, line
+	}
+	file = line[:pos+len(".go")]
+	// log.SetPrefix の接頭辞や日時が前に付いていたら除く。
+	if sp := strings.LastIndex(file, " "); sp >= 0 {
+		file = file[sp+1:]
+	}
+	return trimPrefix(file), n, rest[end+len(": "):]
+}
+
+type slogHandler struct {
+	log Logger
+	// NewSlogHandlerTo でつくったときの書き出し先。
+	direct handler.Handler
+	// WithGroup で付いたグループ名を "." でつないだもの。空でなければ "." で終わる。
+	prefix string
+	// WithAttrs で付いた付加情報。
+	fields []handler.Field
+}
+
+// log に記録する slog.Handler をつくる。
+// slog の重要度は level.FromSlog で変換する。
+// 属性は付加情報にする。グループに入っている属性のキーは "{グループ}.{キー}" にする。
+func NewSlogHandler(log Logger) slog.Handler {
+	return &slogHandler{log: log}
+}
+
+// hndl に直接書き出す slog.Handler をつくる。
+// ロガー名は "" になる。
+func NewSlogHandlerTo(hndl handler.Handler) slog.Handler {
+	log := NewLockLoggerManager().Logger("")
+	log.SetLevel(level.ALL)
+	log.SetUseParent(false)
+	log.AddHandler("slog", hndl)
+	return &slogHandler{log: log, direct: hndl}
+}
+
+func (hndl *slogHandler) Enabled(ctx context.Context, lv slog.Level) bool {
+	rgLv := level.FromSlog(lv)
+	if hndl.direct != nil && rgLv.Lower(hndl.direct.Level()) {
+		return false
+	}
+	if recLog, ok := hndl.log.(recordLogger); ok {
+		return recLog.mayLog(rgLv)
+	}
+	return hndl.log.IsLoggable(rgLv)
+}
+
+func (hndl *slogHandler) Handle(ctx context.Context, r slog.Record) error {
+	lv := level.FromSlog(r.Level)
+	fields := make([]handler.Field, len(hndl.fields), len(hndl.fields)+r.NumAttrs())
+	copy(fields, hndl.fields)
+	r.Attrs(func(attr slog.Attr) bool {
+		fields = appendAttr(fields, hndl.prefix, attr)
+		return true
+	})
+
+	recLog, ok := hndl.log.(recordLogger)
+	if !ok {
+		v := []interface{}{r.Message}
+		for _, field := range fields {
+			v = append(v, field)
+		}
+		hndl.log.LogContext(ctx, lv, v...)
+		return nil
+	}
+
+	rec := &record{
+		date:   r.Time,
+		lv:     lv,
+		msg:    r.Message,
+		fields: fields,
+		file:   "???",
+	}
+	if rec.date.IsZero() {
+		rec.date = time.Now()
+	}
+	if r.PC != 0 {
+		frame, _ := runtime.CallersFrames([]uintptr{r.PC}).Next()
+		rec.file = trimPrefix(frame.File)
+		rec.line = frame.Line
+		rec.function = frame.Function
+	}
+	recLog.logRecord(ctx, rec)
+	return nil
+}
+
+func (hndl *slogHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	if len(attrs) == 0 {
+		return hndl
+	}
+	fields := append([]handler.Field{}, hndl.fields...)
+	for _, attr := range attrs {
+		fields = appendAttr(fields, hndl.prefix, attr)
+	}
+	return &slogHandler{hndl.log, hndl.direct, hndl.prefix, fields}
+}
+
+func (hndl *slogHandler) WithGroup(name string) slog.Handler {
+	if name == "" {
+		return hndl
+	}
+	return &slogHandler{hndl.log, hndl.direct, hndl.prefix + name + ".", hndl.fields}
+}
+
+// slog の属性を付加情報にして足す。
+// グループは中身を "{グループ}.{キー}" にして展開する。
+func appendAttr(fields []handler.Field, prefix string, attr slog.Attr) []handler.Field {
+	attr.Value = attr.Value.Resolve()
+	if attr.Equal(slog.Attr{}) {
+		// slog.Handler の約束で、空の属性は無視する。
+		return fields
+	}
+
+	if attr.Value.Kind() != slog.KindGroup {
+		return append(fields, handler.Field{Key: prefix + attr.Key, Value: attr.Value.Any()})
+	}
+
+	grpPrefix := prefix
+	if attr.Key != "" {
+		// キーの無いグループは中身をそのまま並べる。
+		grpPrefix += attr.Key + "."
+	}
+	for _, sub := range attr.Value.Group() {
+		fields = appendAttr(fields, grpPrefix, sub)
+	}
+	return fields
+}
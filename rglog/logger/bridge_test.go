@@ -0,0 +1,163 @@
+// Copyright 2015 realglobe, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package logger
+
+import (
+	"context"
+	stdlog "log"
+	"log/slog"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/realglobe-Inc/go-lib/rglog/handler"
+	"github.com/realglobe-Inc/go-lib/rglog/level"
+)
+
+func newBridgeTestLogger() (Logger, *recordHandler) {
+	log := NewLockLoggerManager().Logger("a")
+	log.SetLevel(level.INFO)
+	log.SetUseParent(false)
+	hndl := newRecordHandler()
+	log.AddHandler("test", hndl)
+	return log, hndl
+}
+
+func TestParseStdLogLine(t *testing.T) {
+	for _, c := range []struct {
+		line, file string
+		lineNum    int
+		msg        string
+	}{
+		{"/root/go/src/a/b.go:12: abc", "a/b.go", 12, "abc"},
+		{"b.go:3: abc: def", "b.go", 3, "abc: def"},
+		{"prefix: 2006/01/02 15:04:05 b.go:3: abc", "b.go", 3, "abc"},
+		{"abc", "???", 0, "abc"},
+		{"open a.go: no such file", "???", 0, "open a.go: no such file"},
+	} {
+		file, lineNum, msg := parseStdLogLine(c.line)
+		if file != c.file || lineNum != c.lineNum || msg != c.msg {
+			t.Error(c.line, file, lineNum, msg)
+		}
+	}
+}
+
+func TestWriter(t *testing.T) {
+	log, hndl := newBridgeTestLogger()
+
+	std := stdlog.New(NewWriter(log, level.WARN), "", stdlog.Lshortfile)
+	std.Print("abc")
+	line := currentLine() - 1
+	std.Print("def\nghi")
+
+	if len(hndl.recs) != 2 {
+		t.Fatal(hndl.recs)
+	} else if rec := hndl.recs[0]; rec.Level() != level.WARN || rec.File() != "bridge_test.go" || rec.Line() != line || rec.Message() != "abc" {
+		t.Fatal(rec.Level(), rec.File(), rec.Line(), rec.Message())
+	} else if rec := hndl.recs[1]; rec.Message() != "def\nghi" || rec.LoggerName() != "a" {
+		t.Fatal(rec.Message(), rec.LoggerName())
+	}
+
+	stdlog.New(NewWriter(log, level.DEBUG), "", stdlog.Lshortfile).Print("abc")
+	if len(hndl.recs) != 2 {
+		t.Fatal(hndl.recs)
+	}
+}
+
+func TestSlogHandler(t *testing.T) {
+	log, hndl := newBridgeTestLogger()
+	slogger := slog.New(NewSlogHandler(log.With("a", 1)))
+
+	slogger.Debug("debug")
+	slogger.Warn("warn", "b", 2, slog.Group("c", "d", 3, slog.Group("", "e", 4)), slog.Group("f"), slog.Attr{})
+	line := currentLine() - 1
+	if len(hndl.recs) != 1 {
+		t.Fatal(hndl.recs)
+	}
+	rec := hndl.recs[0]
+	if rec.Level() != level.WARN || rec.Message() != "warn" || rec.File() != "github.com/realglobe-Inc/go-lib/rglog/logger/bridge_test.go" || rec.Line() != line {
+		t.Fatal(rec.Level(), rec.Message(), rec.File(), rec.Line())
+	} else if rec.Function() != "github.com/realglobe-Inc/go-lib/rglog/logger.TestSlogHandler" {
+		t.Fatal(rec.Function())
+	} else if fields := rec.Fields(); !reflect.DeepEqual(fields, []handler.Field{{Key: "a", Value: 1}, {Key: "b", Value: int64(2)}, {Key: "c.d", Value: int64(3)}, {Key: "c.e", Value: int64(4)}}) {
+		t.Fatal(fields)
+	}
+}
+
+func TestSlogHandlerWith(t *testing.T) {
+	log, hndl := newBridgeTestLogger()
+	slogger := slog.New(NewSlogHandler(log)).With("a", 1).WithGroup("g").With("b", 2).WithGroup("h")
+
+	date := time.Now()
+	ctx := ContextWithFields(context.Background(), "id", "x")
+	slogger.InfoContext(ctx, "msg", "c", 3)
+	if len(hndl.recs) != 1 {
+		t.Fatal(hndl.recs)
+	}
+	rec := hndl.recs[0]
+	if rec.Date().Before(date) {
+		t.Fatal(rec.Date(), date)
+	} else if fields := rec.Fields(); !reflect.DeepEqual(fields, []handler.Field{{Key: "id", Value: "x"}, {Key: "a", Value: int64(1)}, {Key: "g.b", Value: int64(2)}, {Key: "g.h.c", Value: int64(3)}}) {
+		t.Fatal(fields)
+	}
+}
+
+func TestSlogHandlerTo(t *testing.T) {
+	hndl := newRecordHandler()
+	hndl.SetLevel(level.INFO)
+	slogger := slog.New(NewSlogHandlerTo(hndl)).WithGroup("g").With("a", 1)
+
+	if slogger.Enabled(context.Background(), slog.LevelDebug) {
+		t.Fatal("debug is enabled")
+	}
+	slogger.Debug("debug")
+	slogger.Error("error", "b", 2)
+	line := currentLine() - 1
+	if len(hndl.recs) != 1 {
+		t.Fatal(hndl.recs)
+	}
+	rec := hndl.recs[0]
+	if rec.Level() != level.ERR || rec.Message() != "error" || rec.Line() != line || rec.LoggerName() != "" || rec.Sequence() == 0 {
+		t.Fatal(rec.Level(), rec.Message(), rec.Line(), rec.LoggerName(), rec.Sequence())
+	} else if fields := rec.Fields(); !reflect.DeepEqual(fields, []handler.Field{{Key: "g.a", Value: int64(1)}, {Key: "g.b", Value: int64(2)}}) {
+		t.Fatal(fields)
+	}
+}
+
+// 橋渡しされたログでも、呼び出し元のファイルの重要度の下限が効くか。
+func TestBridgeVModule(t *testing.T) {
+	mgr := NewLockLoggerManager()
+	log := mgr.Logger("a")
+	log.SetLevel(level.INFO)
+	hndl := newRecordHandler()
+	log.AddHandler("test", hndl)
+	mgr.SetVModule(VModule{{"bridge_test.go", level.DEBUG}})
+
+	stdlog.New(NewWriter(log, level.DEBUG), "", stdlog.Llongfile).Print("std")
+	slog.New(NewSlogHandler(log)).Debug("slog")
+	// 他のファイルには効かない。
+	mgr.SetVModule(VModule{{"other.go", level.DEBUG}})
+	stdlog.New(NewWriter(log, level.DEBUG), "", stdlog.Llongfile).Print("std other")
+	slog.New(NewSlogHandler(log)).Debug("slog other")
+
+	if len(hndl.recs) != 2 {
+		t.Fatal(hndl.recs)
+	}
+	for i, msg := range []string{"std", "slog"} {
+		if rec := hndl.recs[i]; rec.Message() != msg || rec.Level() != level.DEBUG {
+			t.Error(i, rec.Message(), rec.Level())
+		}
+	}
+}
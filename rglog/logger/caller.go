@@ -0,0 +1,74 @@
+// Copyright 2015 realglobe, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package logger
+
+import (
+	"runtime"
+	"sync"
+	"sync/atomic"
+)
+
+// 呼び出し元として扱わない関数の名前。
+var helpers sync.Map
+
+// Helper で登録された関数の数。0 なら速い方法で呼び出し元を調べる。
+// 増えたら、呼び出し元の PC ごとに覚えた重要度の下限を捨てる。
+var helperCount int32
+
+// 呼んだ関数を、ログの呼び出し元として扱わないようにする。
+// testing.T.Helper と同じように、ロガーを包む関数の先頭で呼ぶ。
+func Helper() {
+	var pcs [1]uintptr
+	if runtime.Callers(2, pcs[:]) == 0 {
+		return
+	}
+	frame, _ := runtime.CallersFrames(pcs[:]).Next()
+	if _, ok := helpers.LoadOrStore(frame.Function, struct{}{}); !ok {
+		atomic.AddInt32(&helperCount, 1)
+	}
+}
+
+func isHelper(function string) bool {
+	_, ok := helpers.Load(function)
+	return ok
+}
+
+// 呼び出し元を調べる。
+// skip は runtime.Caller と同じように、この関数を呼んだ関数から数える。
+// Helper で登録された関数は飛ばす。
+func callerFrame(skip int) (frame runtime.Frame, ok bool) {
+	if atomic.LoadInt32(&helperCount) == 0 {
+		var pcs [1]uintptr
+		if runtime.Callers(skip+2, pcs[:]) == 0 {
+			return runtime.Frame{}, false
+		}
+		frame, _ = runtime.CallersFrames(pcs[:]).Next()
+		return frame, true
+	}
+
+	// Helper が何重にも重なることはあまり無いだろう。
+	var pcs [16]uintptr
+	n := runtime.Callers(skip+2, pcs[:])
+	if n == 0 {
+		return runtime.Frame{}, false
+	}
+	frames := runtime.CallersFrames(pcs[:n])
+	for {
+		frame, more := frames.Next()
+		if !isHelper(frame.Function) || !more {
+			return frame, true
+		}
+	}
+}
@@ -0,0 +1,59 @@
+// Copyright 2015 realglobe, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package logger
+
+import (
+	"context"
+
+	"github.com/realglobe-Inc/go-lib/rglog/handler"
+)
+
+type contextKey int
+
+const (
+	loggerKey contextKey = iota
+	fieldsKey
+)
+
+// ロガーを入れた context をつくる。
+func NewContext(ctx context.Context, log Logger) context.Context {
+	return context.WithValue(ctx, loggerKey, log)
+}
+
+// context に入っているロガーを返す。
+// 入っていないか、ctx が nil なら nil。
+func FromContext(ctx context.Context) Logger {
+	if ctx == nil {
+		return nil
+	}
+	log, _ := ctx.Value(loggerKey).(Logger)
+	return log
+}
+
+// 付加情報を足した context をつくる。
+// kv は Logger.With と同じ形式。
+// context に入れた付加情報は、LogContext 等でログを取るときに付く。
+func ContextWithFields(ctx context.Context, kv ...interface{}) context.Context {
+	return context.WithValue(ctx, fieldsKey, toFields(kv, ContextFields(ctx)))
+}
+
+// context に入っている付加情報を返す。
+func ContextFields(ctx context.Context) []handler.Field {
+	if ctx == nil {
+		return nil
+	}
+	fields, _ := ctx.Value(fieldsKey).([]handler.Field)
+	return fields
+}
@@ -0,0 +1,49 @@
+// Copyright 2015 realglobe, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package logger
+
+import (
+	"context"
+	"testing"
+)
+
+func TestContextLogger(t *testing.T) {
+	ctx := context.Background()
+	if log := FromContext(ctx); log != nil {
+		t.Fatal(log)
+	} else if log := FromContext(nil); log != nil {
+		t.Fatal(log)
+	}
+
+	log := NewLockLoggerManager().Logger("a")
+	if log2 := FromContext(NewContext(ctx, log)); log2 != log {
+		t.Fatal(log2, log)
+	}
+}
+
+func TestContextFields(t *testing.T) {
+	ctx := context.Background()
+	if fields := ContextFields(ctx); len(fields) != 0 {
+		t.Fatal(fields)
+	}
+
+	ctx1 := ContextWithFields(ctx, "a", 1)
+	ctx2 := ContextWithFields(ctx1, "b", 2)
+	if fields := ContextFields(ctx1); len(fields) != 1 || fields[0].Key != "a" {
+		t.Fatal(fields)
+	} else if fields := ContextFields(ctx2); len(fields) != 2 || fields[0].Key != "a" || fields[1].Key != "b" {
+		t.Fatal(fields)
+	}
+}
@@ -0,0 +1,144 @@
+// Copyright 2015 realglobe, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package logger
+
+import (
+	"fmt"
+
+	"github.com/realglobe-Inc/go-lib/rglog/handler"
+)
+
+// キーになっていない値に付けるキー。
+const badKey = "!BADKEY"
+
+// キーと値を交互に並べたものを base の後ろに付け足す。
+// base は変更しない。
+func toFields(kv []interface{}, base []handler.Field) []handler.Field {
+	fields := make([]handler.Field, len(base), len(base)+len(kv)/2)
+	copy(fields, base)
+	for i := 0; i < len(kv); i++ {
+		switch k := kv[i].(type) {
+		case handler.Field:
+			fields = append(fields, k)
+		case string:
+			if i+1 < len(kv) {
+				fields = append(fields, handler.Field{Key: k, Value: kv[i+1]})
+				i++
+			} else {
+				fields = append(fields, handler.Field{Key: badKey, Value: k})
+			}
+		default:
+			fields = append(fields, handler.Field{Key: badKey, Value: k})
+		}
+	}
+	return fields
+}
+
+// ログの引数と、引数の handler.Field の値からエラーを抜き出す。
+// スタックトレース付きのエラーは、メッセージが 1 行になるように素のエラーに置き換えた引数を返す。v は変更しない。
+func extractErrors(v []interface{}) (args []interface{}, errs []error) {
+	args = v
+	copied := false
+	replace := func(i int, val interface{}) {
+		if !copied {
+			args = append([]interface{}{}, v...)
+			copied = true
+		}
+		args[i] = val
+	}
+
+	for i, val := range v {
+		switch x := val.(type) {
+		case error:
+			errs = append(errs, x)
+			if cause, stack := handler.ErrorStack(x); stack != "" {
+				replace(i, cause)
+			}
+		case handler.Field:
+			err, cause := fieldError(x)
+			if err == nil {
+				continue
+			}
+			errs = append(errs, err)
+			if cause != nil {
+				replace(i, handler.Field{Key: x.Key, Value: cause})
+			}
+		}
+	}
+	return args, errs
+}
+
+// 付加情報の値からエラーを抜き出す。
+// With や context で付けた付加情報用。fields は変更しない。
+func extractFieldErrors(fields []handler.Field) (newFields []handler.Field, errs []error) {
+	newFields = fields
+	copied := false
+	for i, field := range fields {
+		err, cause := fieldError(field)
+		if err == nil {
+			continue
+		}
+		errs = append(errs, err)
+		if cause == nil {
+			continue
+		}
+		if !copied {
+			newFields = append([]handler.Field{}, fields...)
+			copied = true
+		}
+		newFields[i] = handler.Field{Key: field.Key, Value: cause}
+	}
+	return newFields, errs
+}
+
+// 付加情報の値がエラーなら、それを返す。
+// スタックトレース付きなら、置き換え用の素のエラーも返す。
+func fieldError(field handler.Field) (err, cause error) {
+	err, ok := field.Value.(error)
+	if !ok {
+		return nil, nil
+	}
+	if cause, stack := handler.ErrorStack(err); stack != "" {
+		return err, cause
+	}
+	return err, nil
+}
+
+// ログの引数から handler.Field を抜き出して、残りをメッセージにする。
+// 抜き出した分は base の後ろに付け足す。base は変更しない。
+func splitFields(v []interface{}, base []handler.Field) (msg string, fields []handler.Field) {
+	var rest []interface{}
+	for i, val := range v {
+		field, ok := val.(handler.Field)
+		if !ok {
+			if rest != nil {
+				rest = append(rest, val)
+			}
+			continue
+		}
+
+		if rest == nil {
+			// 初めて見つかった。
+			rest = append([]interface{}{}, v[:i]...)
+			fields = append([]handler.Field{}, base...)
+		}
+		fields = append(fields, field)
+	}
+
+	if rest == nil {
+		return fmt.Sprint(v...), base
+	}
+	return fmt.Sprint(rest...), fields
+}
@@ -0,0 +1,285 @@
+// Copyright 2015 realglobe, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package logger
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/realglobe-Inc/go-lib/rglog/handler"
+	"github.com/realglobe-Inc/go-lib/rglog/level"
+)
+
+// 呼ばれる度に、その時点の Manager のロガーに処理させるロガー。
+type indirectLogger struct {
+	name string
+	mgr  func() Manager
+
+	// 付加情報。
+	fields []handler.Field
+}
+
+// 呼ばれる度に mgr() が返す Manager の name のロガーに処理させるロガーをつくる。
+// Manager が差し替えられても、つくり直さずに新しい Manager のロガーを使える。
+// 呼び出し元は、返したロガーのメソッドを呼んだところになる。
+func NewIndirectLogger(name string, mgr func() Manager) Logger {
+	return &indirectLogger{name: name, mgr: mgr}
+}
+
+func (log *indirectLogger) target() Logger {
+	return log.mgr().Logger(log.name)
+}
+
+func (log *indirectLogger) Name() string {
+	return log.name
+}
+
+func (log *indirectLogger) Handler(key string) handler.Handler {
+	return log.target().Handler(key)
+}
+
+func (log *indirectLogger) Handlers() map[string]handler.Handler {
+	return log.target().Handlers()
+}
+
+func (log *indirectLogger) SetHandlers(hndls map[string]handler.Handler) map[string]handler.Handler {
+	return log.target().SetHandlers(hndls)
+}
+
+func (log *indirectLogger) AddHandler(key string, hndl handler.Handler) handler.Handler {
+	return log.target().AddHandler(key, hndl)
+}
+
+func (log *indirectLogger) RemoveHandler(key string) handler.Handler {
+	return log.target().RemoveHandler(key)
+}
+
+func (log *indirectLogger) Level() level.Level {
+	return log.target().Level()
+}
+
+func (log *indirectLogger) SetLevel(lv level.Level) {
+	log.target().SetLevel(lv)
+}
+
+func (log *indirectLogger) UseParent() bool {
+	return log.target().UseParent()
+}
+
+func (log *indirectLogger) SetUseParent(useParent bool) {
+	log.target().SetUseParent(useParent)
+}
+
+func (log *indirectLogger) IsLoggable(lv level.Level) bool {
+	if target, ok := log.target().(*lockLogger); ok {
+		return target.isLoggable(lv, 1)
+	}
+	return log.target().IsLoggable(lv)
+}
+
+func (log *indirectLogger) With(kv ...interface{}) Logger {
+	return &indirectLogger{log.name, log.mgr, toFields(kv, log.fields)}
+}
+
+// ロックせずに lockLogger に処理させる。
+// 付加情報があるときは、それを持たせた lockLogger をその場でつくる。
+func (log *indirectLogger) lockTarget(target *lockLogger) *lockLogger {
+	if len(log.fields) == 0 {
+		return target
+	}
+	return &lockLogger{target.lockLoggerState, append(append([]handler.Field{}, target.fields...), log.fields...)}
+}
+
+// ent はスタックに置けるように、どこにも保存しない。
+func (log *indirectLogger) logging(ctx context.Context, ent *entry) {
+	target := log.target()
+	if lockTarget, ok := target.(*lockLogger); ok {
+		// 付加情報は ent で渡して、捨てられるログでは何もつくらない。
+		// この関数の分だけ余分に遡る。
+		ent.depth++
+		ent.fields = log.fields
+		lockTarget.logging(ctx, ent)
+		return
+	}
+
+	// lockLogger 以外は LogDepth で。
+	if !target.IsLoggable(ent.lv) {
+		return
+	}
+	v := append([]interface{}{}, ent.rawMsg...)
+	if ent.printf {
+		v = []interface{}{fmt.Sprintf(ent.format, v...)}
+	}
+	for _, field := range log.fields {
+		v = append(v, field)
+	}
+	for _, field := range ContextFields(ctx) {
+		v = append(v, field)
+	}
+	target.LogDepth(ent.depth+2, ent.lv, v...)
+}
+
+// 標準の log や slog からの橋渡し用。
+func (log *indirectLogger) mayLog(lv level.Level) bool {
+	target := log.target()
+	if recLog, ok := target.(recordLogger); ok {
+		return recLog.mayLog(lv)
+	}
+	return target.IsLoggable(lv)
+}
+
+// 標準の log や slog からの橋渡し用。
+func (log *indirectLogger) logRecord(ctx context.Context, rec *record) {
+	target := log.target()
+	if lockTarget, ok := target.(*lockLogger); ok {
+		// 捨てられるログでは lockLogger をつくらない。
+		if !lockTarget.mayLog(rec.lv) {
+			return
+		}
+		log.lockTarget(lockTarget).logRecord(ctx, rec)
+		return
+	}
+	v := []interface{}{rec.msg}
+	for _, field := range append(append(append([]handler.Field{}, log.fields...), ContextFields(ctx)...), rec.fields...) {
+		v = append(v, field)
+	}
+	target.Log(rec.lv, v...)
+}
+
+func (log *indirectLogger) Log(lv level.Level, v ...interface{}) {
+	log.logging(context.Background(), &entry{lv: lv, rawMsg: v})
+}
+
+func (log *indirectLogger) Crit(v ...interface{}) {
+	log.logging(context.Background(), &entry{lv: level.CRIT, rawMsg: v})
+}
+
+func (log *indirectLogger) Err(v ...interface{}) {
+	log.logging(context.Background(), &entry{lv: level.ERR, rawMsg: v})
+}
+
+func (log *indirectLogger) Warn(v ...interface{}) {
+	log.logging(context.Background(), &entry{lv: level.WARN, rawMsg: v})
+}
+
+func (log *indirectLogger) Notice(v ...interface{}) {
+	log.logging(context.Background(), &entry{lv: level.NOTICE, rawMsg: v})
+}
+
+func (log *indirectLogger) Info(v ...interface{}) {
+	log.logging(context.Background(), &entry{lv: level.INFO, rawMsg: v})
+}
+
+func (log *indirectLogger) Debug(v ...interface{}) {
+	log.logging(context.Background(), &entry{lv: level.DEBUG, rawMsg: v})
+}
+
+func (log *indirectLogger) Trace(v ...interface{}) {
+	log.logging(context.Background(), &entry{lv: level.TRACE, rawMsg: v})
+}
+
+func (log *indirectLogger) LogDepth(depth int, lv level.Level, v ...interface{}) {
+	log.logging(context.Background(), &entry{lv: lv, depth: depth, rawMsg: v})
+}
+
+func (log *indirectLogger) Logf(lv level.Level, format string, v ...interface{}) {
+	log.logging(context.Background(), &entry{lv: lv, format: format, printf: true, rawMsg: v})
+}
+
+func (log *indirectLogger) Critf(format string, v ...interface{}) {
+	log.logging(context.Background(), &entry{lv: level.CRIT, format: format, printf: true, rawMsg: v})
+}
+
+func (log *indirectLogger) Errf(format string, v ...interface{}) {
+	log.logging(context.Background(), &entry{lv: level.ERR, format: format, printf: true, rawMsg: v})
+}
+
+func (log *indirectLogger) Warnf(format string, v ...interface{}) {
+	log.logging(context.Background(), &entry{lv: level.WARN, format: format, printf: true, rawMsg: v})
+}
+
+func (log *indirectLogger) Noticef(format string, v ...interface{}) {
+	log.logging(context.Background(), &entry{lv: level.NOTICE, format: format, printf: true, rawMsg: v})
+}
+
+func (log *indirectLogger) Infof(format string, v ...interface{}) {
+	log.logging(context.Background(), &entry{lv: level.INFO, format: format, printf: true, rawMsg: v})
+}
+
+func (log *indirectLogger) Debugf(format string, v ...interface{}) {
+	log.logging(context.Background(), &entry{lv: level.DEBUG, format: format, printf: true, rawMsg: v})
+}
+
+func (log *indirectLogger) Tracef(format string, v ...interface{}) {
+	log.logging(context.Background(), &entry{lv: level.TRACE, format: format, printf: true, rawMsg: v})
+}
+
+func (log *indirectLogger) LogContext(ctx context.Context, lv level.Level, v ...interface{}) {
+	log.logging(ctx, &entry{lv: lv, rawMsg: v})
+}
+
+func (log *indirectLogger) CritContext(ctx context.Context, v ...interface{}) {
+	log.logging(ctx, &entry{lv: level.CRIT, rawMsg: v})
+}
+
+func (log *indirectLogger) ErrContext(ctx context.Context, v ...interface{}) {
+	log.logging(ctx, &entry{lv: level.ERR, rawMsg: v})
+}
+
+func (log *indirectLogger) WarnContext(ctx context.Context, v ...interface{}) {
+	log.logging(ctx, &entry{lv: level.WARN, rawMsg: v})
+}
+
+func (log *indirectLogger) NoticeContext(ctx context.Context, v ...interface{}) {
+	log.logging(ctx, &entry{lv: level.NOTICE, rawMsg: v})
+}
+
+func (log *indirectLogger) InfoContext(ctx context.Context, v ...interface{}) {
+	log.logging(ctx, &entry{lv: level.INFO, rawMsg: v})
+}
+
+func (log *indirectLogger) DebugContext(ctx context.Context, v ...interface{}) {
+	log.logging(ctx, &entry{lv: level.DEBUG, rawMsg: v})
+}
+
+func (log *indirectLogger) TraceContext(ctx context.Context, v ...interface{}) {
+	log.logging(ctx, &entry{lv: level.TRACE, rawMsg: v})
+}
+
+func (log *indirectLogger) Fatal(v ...interface{}) {
+	log.logging(context.Background(), &entry{lv: level.FATAL, rawMsg: v})
+	flushWithin(log.mgr(), FatalFlushTimeout)
+	Exit(1)
+}
+
+func (log *indirectLogger) Fatalf(format string, v ...interface{}) {
+	log.logging(context.Background(), &entry{lv: level.FATAL, format: format, printf: true, rawMsg: v})
+	flushWithin(log.mgr(), FatalFlushTimeout)
+	Exit(1)
+}
+
+func (log *indirectLogger) Panic(v ...interface{}) {
+	log.logging(context.Background(), &entry{lv: level.FATAL, rawMsg: v})
+	flushWithin(log.mgr(), FatalFlushTimeout)
+	args, _ := extractErrors(v)
+	msg, _ := splitFields(args, nil)
+	panic(msg)
+}
+
+func (log *indirectLogger) Panicf(format string, v ...interface{}) {
+	log.logging(context.Background(), &entry{lv: level.FATAL, format: format, printf: true, rawMsg: v})
+	flushWithin(log.mgr(), FatalFlushTimeout)
+	panic(fmt.Sprintf(format, v...))
+}
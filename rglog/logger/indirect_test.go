@@ -0,0 +1,131 @@
+// Copyright 2015 realglobe, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package logger
+
+import (
+	"context"
+	"log/slog"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/realglobe-Inc/go-lib/rglog/handler"
+	"github.com/realglobe-Inc/go-lib/rglog/level"
+)
+
+// ファイル名とメッセージだけ書く。
+type fileMessageFormatter struct{}
+
+func (fmter *fileMessageFormatter) Format(rec handler.Record) []byte {
+	return []byte(filepath.Base(rec.File()) + " " + rec.Message() + "\n")
+}
+
+func newIndirectTestManager() (Manager, *handler.MemoryHandler) {
+	mgr := NewLockLoggerManager()
+	hndl := handler.NewMemoryHandlerUsing(&fileMessageFormatter{})
+	log := mgr.Logger("a")
+	log.SetLevel(level.INFO)
+	log.AddHandler("test", hndl)
+	return mgr, hndl
+}
+
+func TestIndirectLogger(t *testing.T) {
+	mgr1, hndl1 := newIndirectTestManager()
+	mgr2, hndl2 := newIndirectTestManager()
+	cur := mgr1
+	log := NewIndirectLogger("a", func() Manager { return cur })
+
+	// 呼び出し元はこのファイルになる。
+	log.Info("info")
+	log.Infof("%s", "infof")
+	log.InfoContext(context.Background(), "context")
+	log.With("k", "v").Info("with")
+	log.Debug("debug")
+	slog.New(NewSlogHandler(log)).Info("slog")
+	if dump := hndl1.Dump(); dump != "indirect_test.go info\nindirect_test.go infof\nindirect_test.go context\nindirect_test.go with\nindirect_test.go slog\n" {
+		t.Fatal(dump)
+	}
+
+	// 差し替えた Manager のロガーに処理させる。
+	cur = mgr2
+	log.Info("info")
+	if dump := hndl2.Dump(); dump != "indirect_test.go info\n" {
+		t.Fatal(dump)
+	} else if strings.Count(hndl1.Dump(), "\n") != 5 {
+		t.Fatal(hndl1.Dump())
+	}
+	if log.Level() != level.INFO || log.Handler("test") != hndl2 {
+		t.Fatal(log.Level(), log.Handlers())
+	}
+}
+
+// With の付加情報も付くか。
+func TestIndirectLoggerWith(t *testing.T) {
+	mgr := NewLockLoggerManager()
+	hndl := handler.NewMemoryHandlerUsing(handler.LevelOnlyFormatter)
+	mgr.Logger("a").SetLevel(level.INFO)
+	mgr.Logger("a").AddHandler("test", hndl)
+	log := NewIndirectLogger("a", func() Manager { return mgr }).With("k", "v")
+
+	log.InfoContext(ContextWithFields(context.Background(), "c", 1), "test")
+	if dump := hndl.Dump(); dump != "[INF] test k=v c=1\n" {
+		t.Fatal(dump)
+	}
+}
+
+// 捨てられるログではメモリ確保しない。
+func TestIndirectLoggerFilteredAllocs(t *testing.T) {
+	mgr, _ := newIndirectTestManager()
+	log := NewIndirectLogger("a", func() Manager { return mgr })
+	if n := testing.AllocsPerRun(100, func() {
+		log.Debug("test message")
+		log.Debugf("test %s", "message")
+		log.IsLoggable(level.DEBUG)
+	}); n != 0 {
+		t.Fatal(n)
+	}
+}
+
+// 付加情報があっても、呼び出し元で捨てられても、捨てられるログではメモリ確保しない。
+func TestIndirectLoggerWithFilteredAllocs(t *testing.T) {
+	mgr, _ := newIndirectTestManager()
+	log := NewIndirectLogger("a", func() Manager { return mgr }).(*indirectLogger).With("id", 1).(*indirectLogger)
+	test := func() {
+		if n := testing.AllocsPerRun(100, func() {
+			log.Debug("test message")
+			log.Debugf("test %s", "message")
+			log.IsLoggable(level.DEBUG)
+		}); n != 0 {
+			t.Error(n)
+		}
+	}
+	test()
+	mgr.(*lockLoggerManager).SetVModule(VModule{{"other.go", level.DEBUG}})
+	test()
+}
+
+func TestIndirectLoggerInterfaceFilteredAllocs(t *testing.T) {
+	mgr := NewLockLoggerManager()
+	testLoggerFilteredAllocs(t, &indirectTestManager{mgr})
+}
+
+// Logger で indirectLogger を返す Manager。
+type indirectTestManager struct {
+	Manager
+}
+
+func (mgr *indirectTestManager) Logger(name string) Logger {
+	return NewIndirectLogger(name, func() Manager { return mgr.Manager })
+}
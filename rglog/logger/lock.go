@@ -15,19 +15,35 @@
 package logger
 
 import (
+	"context"
+	"errors"
 	"fmt"
+	"path"
 	"runtime"
+	"sort"
 	"strings"
 	"sync"
+	"sync/atomic"
 	"time"
 
+	"github.com/realglobe-Inc/go-lib/erro"
 	"github.com/realglobe-Inc/go-lib/rglog/handler"
 	"github.com/realglobe-Inc/go-lib/rglog/level"
 )
 
-// 全部ロックするログ。
+// 設定の変更はロックして行い、変更の度に、ロガーごとに実効的な設定 loggerView をつくり直して差し替える。
+// ログを取るときは loggerView を読むだけなので、ロックしない。
 
 type lockLogger struct {
+	// With でつくったロガー同士で共有する。
+	*lockLoggerState
+
+	// 付加情報。
+	fields []handler.Field
+}
+
+// 名前ごとのロガーの状態。
+type lockLoggerState struct {
 	lock sync.Mutex
 	name string
 
@@ -36,6 +52,76 @@ type lockLogger struct {
 	useParent bool
 
 	mgr *lockLoggerManager // この lockLogger を作成した lockLoggerManager。
+
+	// 先祖まで含めた実効的な設定。*loggerView。
+	view atomic.Value
+}
+
+// ロガーの実効的な設定。
+// 一度つくったら変更しない。
+type loggerView struct {
+	// この設定でハンドラに書き出している最中の数。
+	// 差し替えた後、0 になるのを待てば、外したハンドラにはもう書き出されない。
+	inflight int64
+
+	// これより重要度の低いログはどのハンドラにも渡らない。
+	lv level.Level
+	// これ以上重要なログは全てのハンドラに渡る。呼び出し元を調べなくて良い。
+	maxLv level.Level
+	// 自身から、UseParent で遡れる先祖の順に、ハンドラを持つロガーの分だけ並べる。
+	stages []*viewStage
+}
+
+type viewStage struct {
+	lv    level.Level
+	hndls []handler.Handler
+}
+
+// lv のログがどれかのハンドラに渡るかどうか。
+// vlv は呼び出し元のファイルの重要度の下限。
+func (view *loggerView) accepts(lv, vlv level.Level) bool {
+	return len(view.stages) > 0 && (!lv.Lower(view.lv) || !lv.Lower(vlv))
+}
+
+// lv のログがハンドラに渡るかどうかに、呼び出し元のファイルの重要度の下限が効くかどうか。
+func (view *loggerView) needsCaller(lv level.Level) bool {
+	return len(view.stages) > 0 && lv.Lower(view.maxLv)
+}
+
+func (log *lockLogger) loadView() *loggerView {
+	return log.view.Load().(*loggerView)
+}
+
+// 書き出しに使う loggerView を取得する。
+// 使い終わったら release する。
+func (log *lockLogger) acquireView() *loggerView {
+	for {
+		view := log.loadView()
+		atomic.AddInt64(&view.inflight, 1)
+		if log.loadView() == view {
+			return view
+		}
+		// 差し替えられた。
+		atomic.AddInt64(&view.inflight, -1)
+	}
+}
+
+func (view *loggerView) release() {
+	atomic.AddInt64(&view.inflight, -1)
+}
+
+// 差し替えた loggerView での書き出しが終わるのを待つ。
+// ハンドラの Output の中から呼ぶと、その書き出し自身の終わりを待ち続けて止まる。
+func waitViews(views []*loggerView) {
+	for _, view := range views {
+		for atomic.LoadInt64(&view.inflight) > 0 {
+			time.Sleep(time.Millisecond)
+		}
+	}
+}
+
+func (log *lockLogger) Name() string {
+	return log.name
 }
 
 func (log *lockLogger) Handler(key string) handler.Handler {
@@ -45,24 +131,53 @@ func (log *lockLogger) Handler(key string) handler.Handler {
 	return log.hndls[key]
 }
 
-func (log *lockLogger) AddHandler(key string, hndl handler.Handler) handler.Handler {
+func (log *lockLogger) Handlers() map[string]handler.Handler {
 	log.lock.Lock()
 	defer log.lock.Unlock()
 
+	hndls := map[string]handler.Handler{}
+	for key, hndl := range log.hndls {
+		hndls[key] = hndl
+	}
+	return hndls
+}
+
+func (log *lockLogger) AddHandler(key string, hndl handler.Handler) handler.Handler {
+	log.lock.Lock()
 	old := log.hndls[key]
 	log.hndls[key] = hndl
+	log.lock.Unlock()
+
+	// 置き換えたときだけ、古いハンドラへの書き出しを待つ。
+	waitViews(log.mgr.rebuild(log.name))
 	return old
 }
 
 func (log *lockLogger) RemoveHandler(key string) handler.Handler {
 	log.lock.Lock()
-	defer log.lock.Unlock()
-
 	old := log.hndls[key]
 	delete(log.hndls, key)
+	log.lock.Unlock()
+
+	waitViews(log.mgr.rebuild(log.name))
 	return old
 }
 
+func (log *lockLogger) SetHandlers(hndls map[string]handler.Handler) map[string]handler.Handler {
+	newHndls := map[string]handler.Handler{}
+	for key, hndl := range hndls {
+		newHndls[key] = hndl
+	}
+
+	log.lock.Lock()
+	olds := log.hndls
+	log.hndls = newHndls
+	log.lock.Unlock()
+
+	waitViews(log.mgr.rebuild(log.name))
+	return olds
+}
+
 func (log *lockLogger) Level() level.Level {
 	log.lock.Lock()
 	defer log.lock.Unlock()
@@ -72,9 +187,10 @@ func (log *lockLogger) Level() level.Level {
 
 func (log *lockLogger) SetLevel(lv level.Level) {
 	log.lock.Lock()
-	defer log.lock.Unlock()
-
 	log.lv = lv
+	log.lock.Unlock()
+
+	log.mgr.rebuild(log.name)
 }
 
 func (log *lockLogger) UseParent() bool {
@@ -86,99 +202,251 @@ func (log *lockLogger) UseParent() bool {
 
 func (log *lockLogger) SetUseParent(useParent bool) {
 	log.lock.Lock()
-	defer log.lock.Unlock()
-
 	log.useParent = useParent
+	log.lock.Unlock()
+
+	log.mgr.rebuild(log.name)
 }
 
 func (log *lockLogger) IsLoggable(lv level.Level) bool {
-	cur := log
-
-	for {
-		cur.lock.Lock()
-		curLv := cur.lv
-		hndlNum := len(cur.hndls)
-		useParent := cur.useParent
-		cur.lock.Unlock()
-
-		if !lv.Lower(curLv) && hndlNum > 0 {
-			return true
-		}
-
-		if !useParent {
-			return false
-		}
+	return log.isLoggable(lv, 1)
+}
 
-		newCur := cur.mgr.getParent(cur.name)
-		if newCur == nil {
-			return false
+// skip は callerFrame と同じ。
+func (log *lockLogger) isLoggable(lv level.Level, skip int) bool {
+	view := log.loadView()
+	vlv := level.OFF
+	if view.needsCaller(lv) {
+		if vs := log.mgr.loadVModule(); vs.covers(lv) {
+			var pcs [1]uintptr
+			if runtime.Callers(skip+2, pcs[:]) > 0 {
+				vlv = vs.pcLevel(pcs[0], skip+1)
+			}
 		}
-		cur = newCur
 	}
+	return view.accepts(lv, vlv)
 }
 
-func (log *lockLogger) logging(rec *record) {
-	cur := log
+// 呼び出し元によってはハンドラに渡るかどうか。
+// 呼び出し元が後でわかる橋渡し用。
+func (log *lockLogger) mayLog(lv level.Level) bool {
+	return log.loadView().accepts(lv, log.mgr.vmoduleLevel())
+}
 
-	for {
-		hndls := []handler.Handler{}
-		cur.lock.Lock()
-		lv := cur.lv
-		for _, hndl := range cur.hndls {
-			hndls = append(hndls, hndl)
+// ent はスタックに置けるように、どこにも保存しない。
+// ctx は LogContext 等で呼ばれたときの context。
+func (log *lockLogger) logging(ctx context.Context, ent *entry) {
+	view := log.loadView()
+	vlv := level.OFF
+	if view.needsCaller(ent.lv) {
+		if vs := log.mgr.loadVModule(); vs.covers(ent.lv) {
+			// runtime.Callers は遡る分だけ遅くなるので、ここで調べる。
+			var pcs [1]uintptr
+			if runtime.Callers(3+ent.depth, pcs[:]) > 0 {
+				vlv = vs.pcLevel(pcs[0], 2+ent.depth)
+			}
 		}
-		useParent := cur.useParent
-		cur.lock.Unlock()
+	}
+	if !view.accepts(ent.lv, vlv) {
+		return
+	}
 
-		if !rec.Level().Lower(lv) && len(hndls) > 0 {
-			if rec.file == "" {
-				rec.date = time.Now()
-				if _, file, line, ok := runtime.Caller(2); ok {
-					rec.file = trimPrefix(file)
-					rec.line = line
-				} else {
-					rec.file = "???"
-					rec.line = 0
-				}
-				rec.msg = fmt.Sprint(rec.rawMsg...)
-			}
+	rec := &record{
+		date:   time.Now(),
+		lv:     ent.lv,
+		vlv:    vlv,
+		fields: log.fields,
+	}
+	if len(ent.fields) > 0 {
+		rec.fields = append(append([]handler.Field{}, rec.fields...), ent.fields...)
+	}
+	if frame, ok := callerFrame(2 + ent.depth); ok {
+		rec.file = trimPrefix(frame.File)
+		rec.line = frame.Line
+		rec.function = frame.Function
+	} else {
+		rec.file = "???"
+		rec.line = 0
+	}
+	if ctxFields := ContextFields(ctx); len(ctxFields) > 0 {
+		rec.fields = append(append([]handler.Field{}, rec.fields...), ctxFields...)
+	}
+	rec.fields, rec.errs = extractFieldErrors(rec.fields)
+	args, errs := extractErrors(ent.rawMsg)
+	rec.errs = append(rec.errs, errs...)
+	if ent.printf {
+		rec.msg = fmt.Sprintf(ent.format, args...)
+	} else {
+		rec.msg, rec.fields = splitFields(args, rec.fields)
+	}
+	log.output(rec)
+}
 
-			for _, hndl := range hndls {
-				hndl.Output(rec)
-			}
-		}
+// 呼び出し元、メッセージ、付加情報を決めてあるログを取る。標準の log や slog からの橋渡し用。
+// rec.fields はロガーと ctx の付加情報の後ろに付け足す。付加情報の値のエラーは rec.errs に入れる。
+func (log *lockLogger) logRecord(ctx context.Context, rec *record) {
+	view := log.loadView()
+	if view.needsCaller(rec.lv) {
+		rec.vlv = log.mgr.fileLevel(rec.file, rec.lv)
+	}
+	if !view.accepts(rec.lv, rec.vlv) {
+		return
+	}
 
-		if !useParent {
-			return
-		}
+	fields := append(append([]handler.Field{}, log.fields...), ContextFields(ctx)...)
+	fields, errs := extractFieldErrors(append(fields, rec.fields...))
+	rec.fields = fields
+	rec.errs = append(errs, rec.errs...)
+	log.output(rec)
+}
 
-		// ロック結合した方が良さそうだけど、たぶん大丈夫だろう。
-		newCur := cur.mgr.getParent(cur.name)
-		if newCur == nil {
-			return
+// ハンドラに渡す。
+func (log *lockLogger) output(rec *record) {
+	rec.name = log.name
+	rec.seq = nextSequence()
+	rec.goroutine = goroutineId()
+
+	// 設定が変わっていれば、ここで新しい設定になる。
+	view := log.acquireView()
+	defer view.release()
+	for _, stage := range view.stages {
+		if rec.lv.Lower(stage.lv) && rec.lv.Lower(rec.vlv) {
+			continue
+		}
+		for _, hndl := range stage.hndls {
+			hndl.Output(rec)
 		}
-		cur = newCur
 	}
 }
 
+func (log *lockLogger) With(kv ...interface{}) Logger {
+	return &lockLogger{log.lockLoggerState, toFields(kv, log.fields)}
+}
+
 func (log *lockLogger) Log(lv level.Level, v ...interface{}) {
-	log.logging(&record{lv: lv, rawMsg: v})
+	log.logging(context.Background(), &entry{lv: lv, rawMsg: v})
+}
+
+func (log *lockLogger) Crit(v ...interface{}) {
+	log.logging(context.Background(), &entry{lv: level.CRIT, rawMsg: v})
 }
 
 func (log *lockLogger) Err(v ...interface{}) {
-	log.logging(&record{lv: level.ERR, rawMsg: v})
+	log.logging(context.Background(), &entry{lv: level.ERR, rawMsg: v})
 }
 
 func (log *lockLogger) Warn(v ...interface{}) {
-	log.logging(&record{lv: level.WARN, rawMsg: v})
+	log.logging(context.Background(), &entry{lv: level.WARN, rawMsg: v})
+}
+
+func (log *lockLogger) Notice(v ...interface{}) {
+	log.logging(context.Background(), &entry{lv: level.NOTICE, rawMsg: v})
 }
 
 func (log *lockLogger) Info(v ...interface{}) {
-	log.logging(&record{lv: level.INFO, rawMsg: v})
+	log.logging(context.Background(), &entry{lv: level.INFO, rawMsg: v})
 }
 
 func (log *lockLogger) Debug(v ...interface{}) {
-	log.logging(&record{lv: level.DEBUG, rawMsg: v})
+	log.logging(context.Background(), &entry{lv: level.DEBUG, rawMsg: v})
+}
+
+func (log *lockLogger) Trace(v ...interface{}) {
+	log.logging(context.Background(), &entry{lv: level.TRACE, rawMsg: v})
+}
+
+func (log *lockLogger) LogDepth(depth int, lv level.Level, v ...interface{}) {
+	log.logging(context.Background(), &entry{lv: lv, depth: depth, rawMsg: v})
+}
+
+func (log *lockLogger) Logf(lv level.Level, format string, v ...interface{}) {
+	log.logging(context.Background(), &entry{lv: lv, format: format, printf: true, rawMsg: v})
+}
+
+func (log *lockLogger) Critf(format string, v ...interface{}) {
+	log.logging(context.Background(), &entry{lv: level.CRIT, format: format, printf: true, rawMsg: v})
+}
+
+func (log *lockLogger) Errf(format string, v ...interface{}) {
+	log.logging(context.Background(), &entry{lv: level.ERR, format: format, printf: true, rawMsg: v})
+}
+
+func (log *lockLogger) Warnf(format string, v ...interface{}) {
+	log.logging(context.Background(), &entry{lv: level.WARN, format: format, printf: true, rawMsg: v})
+}
+
+func (log *lockLogger) Noticef(format string, v ...interface{}) {
+	log.logging(context.Background(), &entry{lv: level.NOTICE, format: format, printf: true, rawMsg: v})
+}
+
+func (log *lockLogger) Infof(format string, v ...interface{}) {
+	log.logging(context.Background(), &entry{lv: level.INFO, format: format, printf: true, rawMsg: v})
+}
+
+func (log *lockLogger) Debugf(format string, v ...interface{}) {
+	log.logging(context.Background(), &entry{lv: level.DEBUG, format: format, printf: true, rawMsg: v})
+}
+
+func (log *lockLogger) Tracef(format string, v ...interface{}) {
+	log.logging(context.Background(), &entry{lv: level.TRACE, format: format, printf: true, rawMsg: v})
+}
+
+func (log *lockLogger) LogContext(ctx context.Context, lv level.Level, v ...interface{}) {
+	log.logging(ctx, &entry{lv: lv, rawMsg: v})
+}
+
+func (log *lockLogger) CritContext(ctx context.Context, v ...interface{}) {
+	log.logging(ctx, &entry{lv: level.CRIT, rawMsg: v})
+}
+
+func (log *lockLogger) ErrContext(ctx context.Context, v ...interface{}) {
+	log.logging(ctx, &entry{lv: level.ERR, rawMsg: v})
+}
+
+func (log *lockLogger) WarnContext(ctx context.Context, v ...interface{}) {
+	log.logging(ctx, &entry{lv: level.WARN, rawMsg: v})
+}
+
+func (log *lockLogger) NoticeContext(ctx context.Context, v ...interface{}) {
+	log.logging(ctx, &entry{lv: level.NOTICE, rawMsg: v})
+}
+
+func (log *lockLogger) InfoContext(ctx context.Context, v ...interface{}) {
+	log.logging(ctx, &entry{lv: level.INFO, rawMsg: v})
+}
+
+func (log *lockLogger) DebugContext(ctx context.Context, v ...interface{}) {
+	log.logging(ctx, &entry{lv: level.DEBUG, rawMsg: v})
+}
+
+func (log *lockLogger) TraceContext(ctx context.Context, v ...interface{}) {
+	log.logging(ctx, &entry{lv: level.TRACE, rawMsg: v})
+}
+
+func (log *lockLogger) Fatal(v ...interface{}) {
+	log.logging(context.Background(), &entry{lv: level.FATAL, rawMsg: v})
+	flushWithin(log.mgr, FatalFlushTimeout)
+	Exit(1)
+}
+
+func (log *lockLogger) Fatalf(format string, v ...interface{}) {
+	log.logging(context.Background(), &entry{lv: level.FATAL, format: format, printf: true, rawMsg: v})
+	flushWithin(log.mgr, FatalFlushTimeout)
+	Exit(1)
+}
+
+func (log *lockLogger) Panic(v ...interface{}) {
+	log.logging(context.Background(), &entry{lv: level.FATAL, rawMsg: v})
+	flushWithin(log.mgr, FatalFlushTimeout)
+	args, _ := extractErrors(v)
+	msg, _ := splitFields(args, nil)
+	panic(msg)
+}
+
+func (log *lockLogger) Panicf(format string, v ...interface{}) {
+	log.logging(context.Background(), &entry{lv: level.FATAL, format: format, printf: true, rawMsg: v})
+	flushWithin(log.mgr, FatalFlushTimeout)
+	panic(fmt.Sprintf(format, v...))
 }
 
 func (log *lockLogger) flush() {
@@ -193,24 +461,94 @@ func (log *lockLogger) flush() {
 type lockLoggerManager struct {
 	lock sync.Mutex
 
+	// ソースファイルごとの重要度の下限。*vmoduleState。指定が無ければ nil。
+	vmodule atomic.Value
+
 	// マップで仮想的に木構造を扱う。どうせ深さは 10 もいかない。
 	loggers map[string]*lockLogger
+	// 名前ごとの子孫。まだ無いロガーの名前でも引ける。
+	// 設定を変えたロガーとその子孫だけ loggerView をつくり直すのに使う。
+	descendants map[string][]*lockLogger
+	// 後でつくられるロガーにも適用するワイルドカード付きの重要度の指定。
+	levelRules []*LevelRule
+	// Batch の途中。
+	batching bool
+	// Batch の途中につくられ、まだ公開していないロガー。
+	unpublished []*lockLogger
+	// ロックせずに引くための loggers の写し。キーは名前で、値は *lockLogger。
+	// loggerView をつくってから足す。
+	published sync.Map
 }
 
 func NewLockLoggerManager() *lockLoggerManager {
-	return &lockLoggerManager{loggers: map[string]*lockLogger{}}
+	mgr := &lockLoggerManager{
+		loggers:     map[string]*lockLogger{},
+		descendants: map[string][]*lockLogger{},
+	}
+	mgr.vmodule.Store((*vmoduleState)(nil))
+	return mgr
+}
+
+func (mgr *lockLoggerManager) SetVModule(vmod VModule) {
+	var vs *vmoduleState
+	if len(vmod) > 0 {
+		vs = newVModuleState(append(VModule{}, vmod...))
+	}
+	mgr.vmodule.Store(vs)
+}
+
+// 後でつくられるロガーにも適用する。
+// 同じパターンの指定が既にあれば、前の方を捨てる。
+func (mgr *lockLoggerManager) keepLevelRules(rules []*LevelRule) {
+	mgr.lock.Lock()
+	defer mgr.lock.Unlock()
+
+	for _, rule := range rules {
+		kept := mgr.levelRules[:0]
+		for _, old := range mgr.levelRules {
+			if old.Pattern != rule.Pattern {
+				kept = append(kept, old)
+			}
+		}
+		mgr.levelRules = append(kept, rule)
+	}
+}
+
+// ソースファイルごとの指定。指定が無ければ nil。
+func (mgr *lockLoggerManager) loadVModule() *vmoduleState {
+	return mgr.vmodule.Load().(*vmoduleState)
+}
+
+// ソースファイルごとの指定の中で一番低い重要度。指定が無ければ level.OFF。
+func (mgr *lockLoggerManager) vmoduleLevel() level.Level {
+	if vs := mgr.loadVModule(); vs != nil {
+		return vs.lv
+	}
+	return level.OFF
+}
+
+// lv のログを取ったファイル file の重要度の下限を返す。
+// 指定が無いか、どの指定でも lv が捨てられるなら level.OFF。
+func (mgr *lockLoggerManager) fileLevel(file string, lv level.Level) level.Level {
+	vs := mgr.loadVModule()
+	if !vs.covers(lv) {
+		return level.OFF
+	}
+	return vs.fileLevel(file)
 }
 
 // ロックは外で。
 func (mgr *lockLoggerManager) getParent(name string) *lockLogger {
 	const sep = "/"
 
-	mgr.lock.Lock()
-	defer mgr.lock.Unlock()
 	for curName := name; ; {
 		pos := strings.LastIndex(curName, sep)
 		if pos < 0 {
 			// cur == github.com とか。
+			if name == "" {
+				// "" 自身。
+				return nil
+			}
 			return mgr.loggers[""]
 		}
 
@@ -224,24 +562,250 @@ func (mgr *lockLoggerManager) getParent(name string) *lockLogger {
 	}
 }
 
+// name のロガーとその子孫の loggerView をつくり直す。
+// 差し替えられた loggerView のうち、ハンドラが減ったものを返す。
+func (mgr *lockLoggerManager) rebuild(name string) []*loggerView {
+	mgr.lock.Lock()
+	defer mgr.lock.Unlock()
+
+	return mgr.rebuildLocked(mgr.subtreeLocked(name))
+}
+
+// name のロガーとその子孫を返す。
+// ロックは外で。
+func (mgr *lockLoggerManager) subtreeLocked(name string) []*lockLogger {
+	logs := []*lockLogger{}
+	if log := mgr.loggers[name]; log != nil {
+		logs = append(logs, log)
+	}
+	return append(logs, mgr.descendants[name]...)
+}
+
+// 全てのロガーを返す。
+// ロックは外で。
+func (mgr *lockLoggerManager) allLocked() []*lockLogger {
+	logs := make([]*lockLogger, 0, len(mgr.loggers))
+	for _, log := range mgr.loggers {
+		logs = append(logs, log)
+	}
+	return logs
+}
+
+// logs の loggerView をつくり直す。
+// 差し替えられた loggerView のうち、ハンドラが減ったものを返す。
+// それ以外は、書き出し中のログがあっても、書き出す先は変わらないので待たなくて良い。
+// ロックは外で。
+func (mgr *lockLoggerManager) rebuildLocked(logs []*lockLogger) []*loggerView {
+	olds := []*loggerView{}
+	for _, log := range logs {
+		view := mgr.newView(log)
+		if old, ok := log.view.Load().(*loggerView); ok && !old.handlersIn(view) {
+			olds = append(olds, old)
+		}
+		log.view.Store(view)
+	}
+	return olds
+}
+
+// view のハンドラが全て other にもあるかどうか。
+func (view *loggerView) handlersIn(other *loggerView) bool {
+	for _, stage := range view.stages {
+		for _, hndl := range stage.hndls {
+			if !other.hasHandler(hndl) {
+				return false
+			}
+		}
+	}
+	return true
+}
+
+func (view *loggerView) hasHandler(hndl handler.Handler) bool {
+	for _, stage := range view.stages {
+		for _, h := range stage.hndls {
+			if h == hndl {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// ハンドラを持たないロガーの重要度は、OFF でなければ、先祖のハンドラに処理させるときの下限になる。
+// 複数あれば近い方が勝つ。
+// ロックは外で。
+func (mgr *lockLoggerManager) newView(log *lockLogger) *loggerView {
+	view := &loggerView{lv: level.OFF, maxLv: level.ALL}
+	override := level.OFF
+	for cur := log; cur != nil; cur = mgr.getParent(cur.name) {
+		cur.lock.Lock()
+		lv := cur.lv
+		keys := []string{}
+		for key := range cur.hndls {
+			keys = append(keys, key)
+		}
+		sort.Strings(keys)
+		hndls := []handler.Handler{}
+		for _, key := range keys {
+			hndls = append(hndls, cur.hndls[key])
+		}
+		useParent := cur.useParent
+		cur.lock.Unlock()
+
+		if len(hndls) > 0 {
+			if override != level.OFF {
+				lv = override
+			}
+			view.stages = append(view.stages, &viewStage{lv, hndls})
+			if lv.Lower(view.lv) {
+				view.lv = lv
+			}
+			if view.maxLv.Lower(lv) {
+				view.maxLv = lv
+			}
+		} else if override == level.OFF {
+			override = lv
+		}
+
+		if !useParent {
+			break
+		}
+	}
+	return view
+}
+
 func (mgr *lockLoggerManager) Logger(name string) Logger {
+	if log, ok := mgr.published.Load(name); ok {
+		return log.(*lockLogger)
+	}
+
 	mgr.lock.Lock()
 	defer mgr.lock.Unlock()
 
+	return mgr.loggerLocked(name)
+}
+
+// 無ければつくる。
+// つくったら、そのロガーと、親が変わるかもしれない子孫の loggerView だけつくり直す。
+// Batch の途中なら、つくったロガーを公開しないで、loggerView もつくらない。
+// ロックは外で。
+func (mgr *lockLoggerManager) loggerLocked(name string) *lockLogger {
 	log := mgr.loggers[name]
 	if log == nil {
-		log = &lockLogger{
+		log = &lockLogger{lockLoggerState: &lockLoggerState{
 			name:      name,
 			useParent: true,
 			hndls:     make(map[string]handler.Handler),
 			mgr:       mgr,
+		}}
+		for _, rule := range mgr.levelRules {
+			if ok, _ := path.Match(rule.Pattern, name); ok {
+				log.lv = rule.Level
+			}
 		}
 		mgr.loggers[name] = log
+		for _, ancestor := range ancestorNames(name) {
+			mgr.descendants[ancestor] = append(mgr.descendants[ancestor], log)
+		}
+
+		if mgr.batching {
+			mgr.unpublished = append(mgr.unpublished, log)
+		} else {
+			mgr.rebuildLocked(mgr.subtreeLocked(name))
+			mgr.published.Store(name, log)
+		}
 	}
 
 	return log
 }
 
+// 先祖になりうる名前を返す。
+// "a/b/c" なら "a/b", "a", ""。"" なら無し。
+func ancestorNames(name string) []string {
+	if name == "" {
+		return nil
+	}
+	names := []string{}
+	for pos := strings.LastIndex(name, "/"); pos >= 0; pos = strings.LastIndex(name, "/") {
+		name = name[:pos]
+		names = append(names, name)
+	}
+	return append(names, "")
+}
+
+func (mgr *lockLoggerManager) Batch(fn func(Batch)) {
+	var olds []*loggerView
+	func() {
+		mgr.lock.Lock()
+		defer mgr.lock.Unlock()
+
+		mgr.batching = true
+		defer func() {
+			// fn が panic しても、途中までの変更を反映させる。
+			mgr.batching = false
+			olds = mgr.rebuildLocked(mgr.allLocked())
+			for _, log := range mgr.unpublished {
+				mgr.published.Store(log.name, log)
+			}
+			mgr.unpublished = nil
+		}()
+		fn(&lockBatch{mgr})
+	}()
+
+	waitViews(olds)
+}
+
+// lockLoggerManager のロックを取ったまま、ロガーの状態だけを変える Batch。
+type lockBatch struct {
+	mgr *lockLoggerManager
+}
+
+func (b *lockBatch) Handlers(name string) map[string]handler.Handler {
+	return b.mgr.loggerLocked(name).Handlers()
+}
+
+func (b *lockBatch) SetHandlers(name string, hndls map[string]handler.Handler) map[string]handler.Handler {
+	newHndls := map[string]handler.Handler{}
+	for key, hndl := range hndls {
+		newHndls[key] = hndl
+	}
+
+	log := b.mgr.loggerLocked(name)
+	log.lock.Lock()
+	defer log.lock.Unlock()
+
+	olds := log.hndls
+	log.hndls = newHndls
+	return olds
+}
+
+func (b *lockBatch) SetLevel(name string, lv level.Level) {
+	log := b.mgr.loggerLocked(name)
+	log.lock.Lock()
+	defer log.lock.Unlock()
+
+	log.lv = lv
+}
+
+func (b *lockBatch) SetUseParent(name string, useParent bool) {
+	log := b.mgr.loggerLocked(name)
+	log.lock.Lock()
+	defer log.lock.Unlock()
+
+	log.useParent = useParent
+}
+
+func (mgr *lockLoggerManager) Names() []string {
+	mgr.lock.Lock()
+	defer mgr.lock.Unlock()
+
+	names := []string{}
+	for name := range mgr.loggers {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
 func (mgr *lockLoggerManager) Flush() {
 	// デッドロックしないようにマップをさらってるときに lockLogger 自体の処理はしない。
 	mgr.lock.Lock()
@@ -258,13 +822,101 @@ func (mgr *lockLoggerManager) Flush() {
 	}
 }
 
-type record struct {
-	date   time.Time
+func (mgr *lockLoggerManager) Close(ctx context.Context) error {
+	mgr.lock.Lock()
+	logs := []*lockLogger{}
+	for _, log := range mgr.loggers {
+		logs = append(logs, log)
+	}
+	mgr.lock.Unlock()
+	sort.Slice(logs, func(i, j int) bool { return logs[i].name < logs[j].name })
+
+	errCh := make(chan error, 1)
+	go func() {
+		// 先に全て外しておけば、書き出し中のログを書き終えた後は、どのハンドラにもログは来ない。
+		hndls := []handler.Handler{}
+		seen := map[handler.Handler]bool{}
+		for _, log := range logs {
+			olds := log.SetHandlers(nil)
+			keys := []string{}
+			for key := range olds {
+				keys = append(keys, key)
+			}
+			sort.Strings(keys)
+			for _, key := range keys {
+				if hndl := olds[key]; !seen[hndl] {
+					seen[hndl] = true
+					hndls = append(hndls, hndl)
+				}
+			}
+		}
+
+		// 他のハンドラに書き出すハンドラもあるので、全て Flush してから Close する。
+		for _, hndl := range hndls {
+			hndl.Flush()
+		}
+		errs := []error{}
+		for _, hndl := range hndls {
+			if err := hndl.Close(); err != nil {
+				errs = append(errs, err)
+			}
+		}
+		errCh <- errors.Join(errs...)
+	}()
+
+	select {
+	case err := <-errCh:
+		return erro.Wrap(err)
+	case <-ctx.Done():
+		return erro.Wrap(ctx.Err())
+	}
+}
+
+// timeout までしか待たずに Flush する。
+// 書き出し先が詰まっていても終了できるように。
+func flushWithin(mgr Manager, timeout time.Duration) {
+	done := make(chan struct{})
+	go func() {
+		mgr.Flush()
+		close(done)
+	}()
+
+	timer := time.NewTimer(timeout)
+	defer timer.Stop()
+	select {
+	case <-done:
+	case <-timer.C:
+	}
+}
+
+// ログを取る呼び出しの引数。
+// 呼び出し側のスタックに置けるように、ハンドラに渡す record とは分けている。
+type entry struct {
 	lv     level.Level
-	file   string
-	line   int
-	msg    string
 	rawMsg []interface{}
+	// Logf 等で呼ばれた。
+	printf bool
+	format string
+	// 呼び出し元として余分に遡る数。
+	depth int
+	// ロガーの付加情報の後ろに付け足す付加情報。
+	fields []handler.Field
+}
+
+type record struct {
+	date      time.Time
+	lv        level.Level
+	file      string
+	line      int
+	msg       string
+	fields    []handler.Field
+	errs      []error
+	name      string
+	function  string
+	seq       uint64
+	goroutine uint64
+	// 呼び出し元のファイルの重要度の下限。
+	vlv level.Level
 }
 
 func (rec *record) Date() time.Time {
@@ -282,3 +934,27 @@ func (rec *record) Line() int {
 func (rec *record) Message() string {
 	return rec.msg
 }
+func (rec *record) Fields() []handler.Field {
+	return rec.fields
+}
+func (rec *record) Errors() []error {
+	return rec.errs
+}
+func (rec *record) LoggerName() string {
+	return rec.name
+}
+func (rec *record) Function() string {
+	return rec.function
+}
+func (rec *record) Sequence() uint64 {
+	return rec.seq
+}
+func (rec *record) Pid() int {
+	return pid
+}
+func (rec *record) Hostname() string {
+	return hostname
+}
+func (rec *record) Goroutine() uint64 {
+	return rec.goroutine
+}
@@ -15,7 +15,15 @@
 package logger
 
 import (
+	"context"
+	"strconv"
+	"strings"
+	"sync/atomic"
 	"testing"
+	"time"
+
+	"github.com/realglobe-Inc/go-lib/rglog/handler"
+	"github.com/realglobe-Inc/go-lib/rglog/level"
 )
 
 func TestLockLoggerHandler(t *testing.T) {
@@ -42,6 +50,247 @@ func TestLockLoggerFileName(t *testing.T) {
 	testLoggerFileName(t, NewLockLoggerManager())
 }
 
+func TestLockLoggerWith(t *testing.T) {
+	testLoggerWith(t, NewLockLoggerManager())
+}
+
+func TestLockLoggerLogf(t *testing.T) {
+	testLoggerLogf(t, NewLockLoggerManager())
+}
+
+func TestLockLoggerDepth(t *testing.T) {
+	testLoggerDepth(t, NewLockLoggerManager())
+}
+
+func TestLockLoggerContext(t *testing.T) {
+	testLoggerContext(t, NewLockLoggerManager())
+}
+
+func TestLockLoggerRecordInfo(t *testing.T) {
+	testLoggerRecordInfo(t, NewLockLoggerManager())
+}
+
+func TestLockLoggerErrors(t *testing.T) {
+	testLoggerErrors(t, NewLockLoggerManager())
+}
+
+func TestLockLoggerSetHandlers(t *testing.T) {
+	testLoggerSetHandlers(t, NewLockLoggerManager())
+}
+
+func TestLockLoggerFatal(t *testing.T) {
+	testLoggerFatal(t, NewLockLoggerManager())
+}
+
+func TestLockLoggerPanic(t *testing.T) {
+	testLoggerPanic(t, NewLockLoggerManager())
+}
+
+func TestLockLoggerFatalFlushTimeout(t *testing.T) {
+	mgr := NewLockLoggerManager()
+	log := mgr.Logger("a")
+	block := make(chan struct{})
+	defer close(block)
+	log.AddHandler("block", &blockFlushHandler{handler.NewNopHandler(), block})
+
+	exit, timeout := Exit, FatalFlushTimeout
+	defer func() { Exit, FatalFlushTimeout = exit, timeout }()
+	code := -1
+	Exit = func(c int) { code = c }
+	FatalFlushTimeout = 10 * time.Millisecond
+
+	log.Fatal("fatal")
+	if code != 1 {
+		t.Fatal(code)
+	}
+}
+
+func TestLockManagerClose(t *testing.T) {
+	testManagerClose(t, NewLockLoggerManager())
+}
+
+// ClosableManager でなければ、Close せずに Flush だけするか。
+func TestCloseManagerNotClosable(t *testing.T) {
+	mgr := NewLockLoggerManager()
+	hndl := &countCloseHandler{Handler: handler.NewNopHandler()}
+	mgr.Logger("a").AddHandler("test", hndl)
+	block := &blockFlushHandler{handler.NewNopHandler(), make(chan struct{})}
+	mgr.Logger("b").AddHandler("test", block)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	if err := CloseManager(ctx, struct{ Manager }{mgr}); err != context.DeadlineExceeded {
+		t.Fatal(err)
+	}
+	close(block.block)
+	if err := CloseManager(context.Background(), struct{ Manager }{mgr}); err != nil {
+		t.Fatal(err)
+	} else if n := atomic.LoadInt32(&hndl.closed); n != 0 {
+		t.Fatal(n)
+	}
+}
+
+func TestLockManagerVModule(t *testing.T) {
+	testManagerVModule(t, NewLockLoggerManager())
+}
+
+func TestLockManagerBatch(t *testing.T) {
+	testManagerRunBatch(t, NewLockLoggerManager())
+}
+
+// BatchManager でなくても RunBatch が使えるか。
+func TestLockManagerRunBatchSequential(t *testing.T) {
+	testManagerRunBatch(t, struct{ Manager }{NewLockLoggerManager()})
+}
+
+// Batch の変更は fn が返るまで反映されないか。
+func TestLockManagerBatchAtomic(t *testing.T) {
+	mgr := NewLockLoggerManager()
+	a := mgr.Logger("a")
+	a.SetLevel(level.INFO)
+	a.AddHandler("test", handler.NewNopHandler())
+
+	mgr.Batch(func(b Batch) {
+		b.SetLevel("a", level.DEBUG)
+		b.SetLevel("", level.DEBUG)
+		if a.IsLoggable(level.DEBUG) {
+			t.Error("applied before return")
+		}
+	})
+	if !a.IsLoggable(level.DEBUG) {
+		t.Error("not applied")
+	}
+}
+
+// ハンドラの Output の中から、そのハンドラを外さない設定の変更をしても止まらないか。
+func TestLockLoggerChangeInOutput(t *testing.T) {
+	mgr := NewLockLoggerManager()
+	other := mgr.Logger("other")
+	// ロガーをつくるのも設定の変更になるので、先につくっておく。
+	mgr.Logger("a/b")
+	hndl := &outputFuncHandler{handler.NewNopHandler(), func(rec handler.Record) {
+		// 前に差し替えられた loggerView ではなく、書き出し中の loggerView が差し替えられるように、待ちうるものから。
+		mgr.Logger("a/b").SetHandlers(map[string]handler.Handler{"test": handler.NewNopHandler()})
+		other.AddHandler("a", handler.NewNopHandler())
+		other.AddHandler("a", handler.NewNopHandler())
+		other.RemoveHandler("a")
+		mgr.Logger("a").SetLevel(level.DEBUG)
+		mgr.Logger("a").AddHandler("other", handler.NewNopHandler())
+	}}
+	mgr.Logger("a").SetLevel(level.INFO)
+	mgr.Logger("a").AddHandler("test", hndl)
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		mgr.Logger("a").Info("test")
+	}()
+	select {
+	case <-done:
+	case <-time.After(10 * time.Second):
+		t.Fatal("blocked")
+	}
+}
+
+// 書き出しの遅いハンドラがあっても、それを外さない設定の変更は待たされないか。
+func TestLockLoggerSlowHandler(t *testing.T) {
+	mgr := NewLockLoggerManager()
+	block := make(chan struct{})
+	defer close(block)
+	started := make(chan struct{})
+	mgr.Logger("b")
+	mgr.Logger("a").SetLevel(level.INFO)
+	mgr.Logger("a").AddHandler("slow", &outputFuncHandler{handler.NewNopHandler(), func(rec handler.Record) {
+		close(started)
+		<-block
+	}})
+	go mgr.Logger("a").Info("test")
+	<-started
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		mgr.Logger("b").SetHandlers(map[string]handler.Handler{"test": handler.NewNopHandler()})
+		mgr.Logger("a").AddHandler("new", handler.NewNopHandler())
+		mgr.Logger("").AddHandler("new", handler.NewNopHandler())
+		LevelSpec{{Pattern: "a", Level: level.DEBUG}}.Apply(mgr)
+	}()
+	select {
+	case <-done:
+	case <-time.After(10 * time.Second):
+		t.Fatal("blocked")
+	}
+}
+
+// ロガーをつくっても、そのロガーと子孫の loggerView だけつくり直すか。
+func TestLockManagerRebuildSubtree(t *testing.T) {
+	mgr := NewLockLoggerManager()
+	hndl := handler.NewMemoryHandlerUsing(handler.LevelOnlyFormatter)
+	mgr.Logger("").SetLevel(level.INFO)
+	mgr.Logger("").AddHandler("test", hndl)
+	c := mgr.Logger("a/b/c").(*lockLogger)
+	x := mgr.Logger("x").(*lockLogger)
+	xView := x.loadView()
+
+	// 間にロガーができたら、子孫はそれを親にする。
+	a := mgr.Logger("a")
+	if x.loadView() != xView {
+		t.Error("unrelated view is rebuilt")
+	}
+	a.SetUseParent(false)
+	if x.loadView() != xView {
+		t.Error("unrelated view is rebuilt")
+	}
+	c.Info("c")
+	x.Info("x")
+	if dump := hndl.Dump(); dump != "[INF] x\n" {
+		t.Error(dump)
+	}
+}
+
+func BenchmarkLockManagerNewLogger(b *testing.B) {
+	mgr := NewLockLoggerManager()
+	mgr.Logger("").AddHandler("test", handler.NewNopHandler())
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		mgr.Logger("a/" + strconv.Itoa(i))
+	}
+}
+
+func TestLockManagerCloseTimeout(t *testing.T) {
+	mgr := NewLockLoggerManager()
+	block := make(chan struct{})
+	defer close(block)
+	mgr.Logger("a").AddHandler("block", &blockFlushHandler{handler.NewNopHandler(), block})
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	if err := mgr.Close(ctx); err == nil || !strings.Contains(err.Error(), context.DeadlineExceeded.Error()) {
+		t.Fatal(err)
+	}
+}
+
+// Output だけ差し替えたハンドラ。
+type outputFuncHandler struct {
+	handler.Handler
+	output func(rec handler.Record)
+}
+
+func (hndl *outputFuncHandler) Output(rec handler.Record) {
+	hndl.output(rec)
+}
+
+// Flush が終わらないハンドラ。
+type blockFlushHandler struct {
+	handler.Handler
+	block chan struct{}
+}
+
+func (hndl *blockFlushHandler) Flush() {
+	<-hndl.block
+}
+
 func TestLockLoggerConcurrent(t *testing.T) {
 	testLoggerConcurrent(t, NewLockLoggerManager())
 }
@@ -49,3 +298,103 @@ func TestLockLoggerConcurrent(t *testing.T) {
 func BenchmarkLockLoggerConcurrent(b *testing.B) {
 	benchmarkLoggerConcurrent(b, NewLockLoggerManager())
 }
+
+func newFilteringLockLogger() *lockLogger {
+	mgr := NewLockLoggerManager()
+	log := mgr.Logger("a/b/c").(*lockLogger)
+	log.SetLevel(level.INFO)
+	log.AddHandler("test", handler.NewNopHandler())
+	mgr.Logger("a/b").SetLevel(level.WARN)
+	mgr.Logger("a/b").AddHandler("test", handler.NewNopHandler())
+	return log
+}
+
+// 捨てられるログではメモリ確保しない。
+func TestLockLoggerFilteredAllocs(t *testing.T) {
+	log := newFilteringLockLogger()
+	if n := testing.AllocsPerRun(100, func() {
+		log.Debug("test message")
+		log.Debugf("test %s", "message")
+		log.IsLoggable(level.DEBUG)
+	}); n != 0 {
+		t.Fatal(n)
+	}
+}
+
+func TestLockLoggerInterfaceFilteredAllocs(t *testing.T) {
+	testLoggerFilteredAllocs(t, NewLockLoggerManager())
+}
+
+// ソースファイルごとの重要度の指定があっても、捨てられるログではメモリ確保しない。
+func TestLockLoggerVModuleFilteredAllocs(t *testing.T) {
+	log := newFilteringLockLogger()
+	log.mgr.SetVModule(VModule{{"other.go", level.DEBUG}})
+	if n := testing.AllocsPerRun(100, func() {
+		log.Debug("test message")
+		log.Debugf("test %s", "message")
+		log.IsLoggable(level.DEBUG)
+	}); n != 0 {
+		t.Fatal(n)
+	}
+}
+
+func BenchmarkLockLoggerVModuleFiltered(b *testing.B) {
+	log := newFilteringLockLogger()
+	log.mgr.SetVModule(VModule{{"other.go", level.DEBUG}})
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		log.Debug("test message")
+	}
+}
+
+// ロガーの重要度で全てのハンドラに渡ると決まるなら、呼び出し元を調べない。
+func BenchmarkLockLoggerVModuleLoggable(b *testing.B) {
+	log := newFilteringLockLogger()
+	log.mgr.SetVModule(VModule{{"other.go", level.DEBUG}})
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		log.IsLoggable(level.WARN)
+	}
+}
+
+func BenchmarkLockLoggerFiltered(b *testing.B) {
+	log := newFilteringLockLogger()
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		log.Debug("test message")
+	}
+}
+
+// Logger インターフェースを通す。
+// 可変長引数のスライスは呼び出し側がヒープに確保するので、1 回確保する。
+func BenchmarkLockLoggerFilteredInterface(b *testing.B) {
+	testAllocsLogger = newFilteringLockLogger().With("id", 1)
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		testAllocsLogger.Debug("test message")
+	}
+}
+
+func BenchmarkLockLoggerFilteredParallel(b *testing.B) {
+	log := newFilteringLockLogger()
+	b.ReportAllocs()
+	b.ResetTimer()
+	b.RunParallel(func(pb *testing.PB) {
+		for pb.Next() {
+			log.Debug("test message")
+		}
+	})
+}
+
+func BenchmarkLockLoggerIsLoggable(b *testing.B) {
+	var log Logger = newFilteringLockLogger()
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		log.IsLoggable(level.DEBUG)
+	}
+}
@@ -15,23 +15,44 @@
 package logger
 
 import (
+	"context"
+	"os"
+	"time"
+
 	"github.com/realglobe-Inc/go-lib/rglog/handler"
 	"github.com/realglobe-Inc/go-lib/rglog/level"
 )
 
 type Logger interface {
+	// 名前。
+	Name() string
+
 	// 登録してあるハンドラを取得する。
 	Handler(key string) handler.Handler
+	// 登録してある全てのハンドラを取得する。
+	Handlers() map[string]handler.Handler
+	// 登録してあるハンドラをまとめて置き換える。
+	// 置き換えられたハンドラを返す。
+	// 返ったときには、置き換えられたハンドラにはもう書き出されないので、Close して良い。
+	// そのために、外れたハンドラへの書き出し中のログがあれば、それが終わるのを待つ。
+	// ハンドラの Output の中から呼ぶとデッドロックする。
+	SetHandlers(hndls map[string]handler.Handler) map[string]handler.Handler
 	// ハンドラを登録する。
 	// 既に同じ key でハンドラが登録してあったら、新しい方に置き換えて、古い方を返す。
+	// 置き換えたときは SetHandlers と同じく、古い方への書き出しが終わるのを待つ。
+	// ハンドラの Output の中から呼ぶとデッドロックする。
 	AddHandler(key string, hndl handler.Handler) (oldHndl handler.Handler)
 	// ハンドラを登録から外す。
+	// SetHandlers と同じく、外したハンドラへの書き出しが終わるのを待つ。
+	// ハンドラの Output の中から呼ぶとデッドロックする。
 	RemoveHandler(key string) (oldHndl handler.Handler)
 
 	// ハンドラに処理させる重要度の下限を返す。
 	// 初期値は level.OFF。
 	Level() level.Level
 	// ハンドラに処理させる重要度の下限を指定する。
+	// ハンドラを持たないロガーでは、OFF 以外を指定すると、UseParent で遡る先祖のハンドラに処理させるときの下限になる。
+	// 遡る途中に別のハンドラを持たないロガーの指定があれば、近い方が勝つ。
 	SetLevel(lv level.Level)
 
 	// 識別子を / 区切りの木構造として、親の識別子のロガーにも処理させるかどうか。
@@ -43,19 +64,188 @@ type Logger interface {
 	// UseParent が true な限りの先祖ロガーも含む。
 	IsLoggable(lv level.Level) bool
 
+	// 付加情報を付けてログを取るロガーを返す。
+	// kv はキーと値を交互に並べたもの。handler.Field も混ぜられる。
+	// 返したロガーの設定は元のロガーと共有する。
+	With(kv ...interface{}) Logger
+
 	// ログを取る。
+	// v に含まれる handler.Field は、メッセージではなく付加情報になる。
 	Log(lv level.Level, v ...interface{})
+	// 呼び出し元を depth 個余分に遡ったところとしてログを取る。
+	// ロガーを包む関数から使う。LogDepth(0, lv, v...) は Log(lv, v...) と一緒。
+	LogDepth(depth int, lv level.Level, v ...interface{})
+	// Log(level.CRIT, v...) と一緒。
+	Crit(v ...interface{})
 	// Log(level.ERR, v...) と一緒。
 	Err(v ...interface{})
 	// Log(level.WARN, v...) と一緒。
 	Warn(v ...interface{})
+	// Log(level.NOTICE, v...) と一緒。
+	Notice(v ...interface{})
 	// Log(level.INFO, v...) と一緒。
 	Info(v ...interface{})
 	// Log(level.DEBUG, v...) と一緒。
 	Debug(v ...interface{})
+	// Log(level.TRACE, v...) と一緒。
+	Trace(v ...interface{})
+
+	// fmt.Sprintf 形式でログを取る。
+	// 書式化はハンドラに処理させると決まってから行う。
+	// v に含まれる handler.Field も書式の引数として扱う。
+	Logf(lv level.Level, format string, v ...interface{})
+	// Logf(level.CRIT, format, v...) と一緒。
+	Critf(format string, v ...interface{})
+	// Logf(level.ERR, format, v...) と一緒。
+	Errf(format string, v ...interface{})
+	// Logf(level.WARN, format, v...) と一緒。
+	Warnf(format string, v ...interface{})
+	// Logf(level.NOTICE, format, v...) と一緒。
+	Noticef(format string, v ...interface{})
+	// Logf(level.INFO, format, v...) と一緒。
+	Infof(format string, v ...interface{})
+	// Logf(level.DEBUG, format, v...) と一緒。
+	Debugf(format string, v ...interface{})
+	// Logf(level.TRACE, format, v...) と一緒。
+	Tracef(format string, v ...interface{})
+
+	// ctx に入っている付加情報を付けてログを取る。
+	// 付加情報は ContextWithFields で入れておく。
+	LogContext(ctx context.Context, lv level.Level, v ...interface{})
+	// LogContext(ctx, level.CRIT, v...) と一緒。
+	CritContext(ctx context.Context, v ...interface{})
+	// LogContext(ctx, level.ERR, v...) と一緒。
+	ErrContext(ctx context.Context, v ...interface{})
+	// LogContext(ctx, level.WARN, v...) と一緒。
+	WarnContext(ctx context.Context, v ...interface{})
+	// LogContext(ctx, level.NOTICE, v...) と一緒。
+	NoticeContext(ctx context.Context, v ...interface{})
+	// LogContext(ctx, level.INFO, v...) と一緒。
+	InfoContext(ctx context.Context, v ...interface{})
+	// LogContext(ctx, level.DEBUG, v...) と一緒。
+	DebugContext(ctx context.Context, v ...interface{})
+	// LogContext(ctx, level.TRACE, v...) と一緒。
+	TraceContext(ctx context.Context, v ...interface{})
+
+	// level.FATAL でログを取り、ハンドラを Flush してから Exit(1) する。
+	// Flush は FatalFlushTimeout までしか待たない。
+	Fatal(v ...interface{})
+	// Fatal の fmt.Sprintf 形式。
+	Fatalf(format string, v ...interface{})
+	// level.FATAL でログを取り、ハンドラを Flush してから、メッセージで panic する。
+	// Flush は FatalFlushTimeout までしか待たない。
+	Panic(v ...interface{})
+	// Panic の fmt.Sprintf 形式。
+	Panicf(format string, v ...interface{})
 }
 
+// Fatal 等で呼ぶ終了関数。テストでは差し替える。
+var Exit = os.Exit
+
+// Fatal, Panic でハンドラの Flush を待つ時間。
+var FatalFlushTimeout = 5 * time.Second
+
+// ロガーをまとめて管理する。
 type Manager interface {
 	Logger(name string) Logger
+	// 作成済みのロガーの名前を列挙する。
+	Names() []string
 	Flush()
 }
+
+// 全てのハンドラを Close できる Manager。
+type ClosableManager interface {
+	Manager
+	// 全ロガーからハンドラを外してから、全てのハンドラを Flush し、Close する。
+	// 複数のロガーに登録されているハンドラも 1 回しか Close しない。
+	// Close のエラーはまとめて返す。
+	// ctx が終わったら、終わっていない処理を待たずに ctx.Err() を返す。
+	// Close した後もロガーは使えるが、ハンドラを登録し直さない限りログは捨てられる。
+	// ハンドラの Output の中から呼ぶと、外したハンドラへの書き出しの終わりを待ち続けて ctx が終わるまで返らない。
+	Close(ctx context.Context) error
+}
+
+// mgr が ClosableManager なら Close する。
+// そうでなければ Flush だけする。
+// ctx が終わったら、終わっていない処理を待たずに ctx.Err() を返す。
+func CloseManager(ctx context.Context, mgr Manager) error {
+	if cmgr, ok := mgr.(ClosableManager); ok {
+		return cmgr.Close(ctx)
+	}
+
+	done := make(chan struct{})
+	go func() {
+		mgr.Flush()
+		close(done)
+	}()
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// ソースファイルごとの重要度の下限を指定できる Manager。
+type VModuleManager interface {
+	Manager
+	// ソースファイルごとの重要度の下限を vmod にする。前の指定は捨てる。
+	// 空なら指定を解除する。
+	SetVModule(vmod VModule)
+}
+
+// 複数のロガーの設定をまとめて変えるためのもの。
+// ロガーは名前で指定し、無ければつくる。
+type Batch interface {
+	// 登録してある全てのハンドラを取得する。
+	Handlers(name string) map[string]handler.Handler
+	// 登録してあるハンドラをまとめて置き換える。
+	// 置き換えられたハンドラを返す。
+	SetHandlers(name string, hndls map[string]handler.Handler) map[string]handler.Handler
+	// ハンドラに処理させる重要度の下限を指定する。
+	SetLevel(name string, lv level.Level)
+	// 親の識別子のロガーにも処理させるかどうかを指定する。
+	SetUseParent(name string, useParent bool)
+}
+
+// 複数のロガーの設定の変更を一度に反映させられる Manager。
+type BatchManager interface {
+	Manager
+	// fn の中で Batch に行った変更を、fn が返ってから全てのロガーに一度に反映させる。
+	// 返ったときには、置き換えられたハンドラにはもう書き出されないので、Close して良い。
+	// そのために、外れたハンドラへの書き出し中のログがあれば、それが終わるのを待つ。
+	// fn の中で Manager や Logger の設定を変えたり、ロガーを取得したりするとデッドロックする。
+	// ハンドラを外すなら、ハンドラの Output の中から呼ぶとデッドロックする。
+	Batch(fn func(Batch))
+}
+
+// mgr が BatchManager なら、fn の中で行った変更を一度に反映させる。
+// そうでなければ、1 つずつ反映させる。
+func RunBatch(mgr Manager, fn func(Batch)) {
+	if bmgr, ok := mgr.(BatchManager); ok {
+		bmgr.Batch(fn)
+		return
+	}
+	fn(&sequentialBatch{mgr})
+}
+
+// 1 つずつ反映させる Batch。
+type sequentialBatch struct {
+	mgr Manager
+}
+
+func (b *sequentialBatch) Handlers(name string) map[string]handler.Handler {
+	return b.mgr.Logger(name).Handlers()
+}
+
+func (b *sequentialBatch) SetHandlers(name string, hndls map[string]handler.Handler) map[string]handler.Handler {
+	return b.mgr.Logger(name).SetHandlers(hndls)
+}
+
+func (b *sequentialBatch) SetLevel(name string, lv level.Level) {
+	b.mgr.Logger(name).SetLevel(lv)
+}
+
+func (b *sequentialBatch) SetUseParent(name string, useParent bool) {
+	b.mgr.Logger(name).SetUseParent(useParent)
+}
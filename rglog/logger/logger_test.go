@@ -15,13 +15,21 @@
 package logger
 
 import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
 	"os"
 	"path/filepath"
+	"runtime"
 	"strconv"
 	"strings"
+	"sync"
+	"sync/atomic"
 	"testing"
 	"time"
 
+	"github.com/realglobe-Inc/go-lib/erro"
 	"github.com/realglobe-Inc/go-lib/rglog/handler"
 	"github.com/realglobe-Inc/go-lib/rglog/level"
 )
@@ -48,6 +56,10 @@ func testLoggerHandler(t *testing.T, mgr Manager) {
 		t.Fatal(hndl, oldHndl)
 	}
 
+	if hndls := log.Handlers(); len(hndls) != 1 || hndls["test"] != memHndl {
+		t.Fatal(hndls)
+	}
+
 	if hndl := log.RemoveHandler("test"); hndl != memHndl {
 		t.Fatal(hndl, memHndl)
 	}
@@ -88,6 +100,7 @@ func testLoggerIsLoggable(t *testing.T, mgr Manager) {
 	// ハンドラが無い     false                false                    true
 	// 基準重要度より低い false                false                    true
 	// 基準重要度より高い true                 true                     true
+	// ハンドラが無い行は重要度が OFF の場合。OFF でなければ先祖の基準重要度の代わりになる。
 
 	log := mgr.Logger("a/b/c/d")
 	log.SetLevel(level.INFO)
@@ -114,6 +127,7 @@ func testLoggerIsLoggable(t *testing.T, mgr Manager) {
 	}
 
 	log.RemoveHandler("test")
+	log.SetLevel(level.OFF)
 	parentLog.AddHandler("test", handler.NewNopHandler())
 	parentLog.SetLevel(level.WARN)
 
@@ -122,6 +136,14 @@ func testLoggerIsLoggable(t *testing.T, mgr Manager) {
 		t.Fatal("true: no handler, lower level")
 	}
 
+	// ハンドラが無いが重要度の指定がある、先祖の基準重要度より低い。
+	log.SetLevel(level.INFO)
+	if !log.IsLoggable(level.INFO) {
+		t.Fatal("false: no handler with level, lower level")
+	} else if log.IsLoggable(level.DEBUG) {
+		t.Fatal("true: no handler with level, lower level")
+	}
+
 	log.AddHandler("test", handler.NewNopHandler())
 
 	// 基準重要度より低い、先祖の基準重要度より低い。
@@ -135,6 +157,7 @@ func testLoggerIsLoggable(t *testing.T, mgr Manager) {
 	}
 
 	log.RemoveHandler("test")
+	log.SetLevel(level.OFF)
 	parentLog.AddHandler("test", handler.NewNopHandler())
 	parentLog.SetLevel(level.DEBUG)
 
@@ -143,6 +166,12 @@ func testLoggerIsLoggable(t *testing.T, mgr Manager) {
 		t.Fatal("false: no handler, upper level")
 	}
 
+	// ハンドラが無いが重要度の指定がある、先祖の基準重要度より高い。
+	log.SetLevel(level.INFO)
+	if log.IsLoggable(level.DEBUG) {
+		t.Fatal("true: no handler with level, upper level")
+	}
+
 	log.AddHandler("test", handler.NewNopHandler())
 
 	// 基準重要度より低い、先祖の基準重要度より高い。
@@ -185,7 +214,7 @@ func testLoggerFileName(t *testing.T, mgr Manager) {
 		t.Fatal(dum)
 	}
 
-	for _, logging := range []func(...interface{}){log.Err, log.Warn, log.Info, log.Debug} {
+	for _, logging := range []func(...interface{}){log.Crit, log.Err, log.Warn, log.Notice, log.Info, log.Debug, log.Trace} {
 		hndl := handler.NewMemoryHandlerUsing(&fileOnlyFormatter{})
 		hndl.SetLevel(level.ALL)
 		log.AddHandler("test", hndl)
@@ -307,3 +336,569 @@ func benchmarkLoggerConcurrent(b *testing.B, mgr Manager) {
 
 	mgr.Flush()
 }
+
+func testLoggerWith(t *testing.T, mgr Manager) {
+	log := mgr.Logger("a/b/c")
+	log.SetLevel(level.ALL)
+	log.SetUseParent(false)
+
+	hndl := handler.NewMemoryHandlerUsing(handler.LevelOnlyFormatter)
+	log.AddHandler("test", hndl)
+
+	log2 := log.With("id", 1, handler.Field{Key: "user", Value: "a"})
+	log3 := log2.With("odd")
+	log2.Info("test ", handler.Field{Key: "took", Value: 2}, "message")
+	log3.Info("test")
+	log.Info(handler.Field{Key: "only", Value: true})
+
+	if buff := hndl.Dump(); buff != "[INF] test message id=1 user=a took=2\n"+
+		"[INF] test id=1 user=a !BADKEY=odd\n"+
+		"[INF]  only=true\n" {
+		t.Fatal(buff)
+	}
+
+	// 設定は共有する。
+	log2.SetLevel(level.WARN)
+	if log.Level() != level.WARN {
+		t.Fatal(log.Level())
+	}
+
+	// ファイル名も元のロガーと同じように解決できる。
+	fileHndl := handler.NewMemoryHandlerUsing(&fileOnlyFormatter{})
+	log.AddHandler("test", fileHndl)
+	for _, logging := range []func(...interface{}){log2.Crit, log2.Err, log2.Warn} {
+		logging("")
+	}
+	log2.Log(level.ERR, "")
+	file := filepath.Join("github.com", "realglobe-Inc", "go-lib", "rglog", "logger", "logger_test.go")
+	if dum := fileHndl.Dump(); dum != file+file+file+file {
+		t.Fatal(dum)
+	}
+}
+
+// String が呼ばれた回数を数える。
+type countStringer struct {
+	n int
+}
+
+func (s *countStringer) String() string {
+	s.n++
+	return "counter"
+}
+
+func testLoggerLogf(t *testing.T, mgr Manager) {
+	log := mgr.Logger("a/b/c")
+	log.SetLevel(level.INFO)
+	log.SetUseParent(false)
+
+	hndl := handler.NewMemoryHandlerUsing(handler.LevelOnlyFormatter)
+	log.AddHandler("test", hndl)
+
+	var s countStringer
+	log.Debugf("%v %d", &s, 1)
+	if s.n != 0 {
+		t.Fatal("formatted filtered record")
+	}
+	log.Infof("%v %d", &s, 1)
+	if s.n != 1 {
+		t.Fatal(s.n)
+	}
+	log.With("id", 2).Warnf("%03d", 3)
+	if buff := hndl.Dump(); buff != "[INF] counter 1\n[WAR] 003 id=2\n" {
+		t.Fatal(buff)
+	}
+
+	fileHndl := handler.NewMemoryHandlerUsing(&fileOnlyFormatter{})
+	log.AddHandler("test", fileHndl)
+	log.SetLevel(level.ALL)
+	for _, logging := range []func(string, ...interface{}){log.Critf, log.Errf, log.Warnf, log.Noticef, log.Infof, log.Debugf, log.Tracef} {
+		logging("")
+	}
+	log.Logf(level.INFO, "")
+	file := filepath.Join("github.com", "realglobe-Inc", "go-lib", "rglog", "logger", "logger_test.go")
+	if dum := fileHndl.Dump(); dum != strings.Repeat(file, 8) {
+		t.Fatal(dum)
+	}
+}
+
+type lineOnlyFormatter struct{}
+
+func (fmter *lineOnlyFormatter) Format(rec handler.Record) []byte {
+	return []byte(strconv.Itoa(rec.Line()) + " ")
+}
+
+func currentLine() int {
+	_, _, line, _ := runtime.Caller(1)
+	return line
+}
+
+// ロガーを包む関数の例。
+func logByWrapper(log Logger, v ...interface{}) {
+	log.LogDepth(1, level.INFO, v...)
+}
+
+// Helper を使ってロガーを包む関数の例。
+func logByHelper(log Logger, v ...interface{}) {
+	Helper()
+	log.Info(v...)
+}
+
+// DEBUG で取る。vmodule_test.go から呼んで、呼び出し元のファイルで重要度の下限が決まるか調べる。
+func debugByHelper(log Logger, v ...interface{}) {
+	Helper()
+	log.Debug(v...)
+}
+
+// helper なら Helper で登録する。
+func debugByLateHelper(log Logger, helper bool, v ...interface{}) {
+	if helper {
+		Helper()
+	}
+	log.Debug(v...)
+}
+
+func logByNestedHelper(log Logger, v ...interface{}) {
+	Helper()
+	logByHelper(log, v...)
+}
+
+func testLoggerDepth(t *testing.T, mgr Manager) {
+	log := mgr.Logger("a/b/c")
+	log.SetLevel(level.ALL)
+	log.SetUseParent(false)
+
+	hndl := handler.NewMemoryHandlerUsing(&lineOnlyFormatter{})
+	log.AddHandler("test", hndl)
+
+	line := currentLine()
+	log.LogDepth(0, level.INFO, "")
+	logByWrapper(log, "")
+	logByHelper(log, "")
+	logByNestedHelper(log.With("a", 1), "")
+	expected := ""
+	for i := 1; i <= 4; i++ {
+		expected += strconv.Itoa(line+i) + " "
+	}
+	if buff := hndl.Dump(); buff != expected {
+		t.Fatal(buff, expected)
+	}
+}
+
+func testLoggerContext(t *testing.T, mgr Manager) {
+	log := mgr.Logger("a/b/c")
+	log.SetLevel(level.ALL)
+	log.SetUseParent(false)
+
+	hndl := handler.NewMemoryHandlerUsing(handler.LevelOnlyFormatter)
+	log.AddHandler("test", hndl)
+
+	ctx := ContextWithFields(context.Background(), "request", "abc")
+	ctx = ContextWithFields(ctx, "tenant", 1)
+	log.With("id", 2).InfoContext(ctx, "test", handler.Field{Key: "took", Value: 3})
+	log.LogContext(context.Background(), level.WARN, "no fields")
+	if buff := hndl.Dump(); buff != "[INF] test id=2 request=abc tenant=1 took=3\n[WAR] no fields\n" {
+		t.Fatal(buff)
+	}
+
+	fileHndl := handler.NewMemoryHandlerUsing(&fileOnlyFormatter{})
+	log.AddHandler("test", fileHndl)
+	for _, logging := range []func(context.Context, ...interface{}){log.CritContext, log.ErrContext, log.WarnContext, log.NoticeContext, log.InfoContext, log.DebugContext, log.TraceContext} {
+		logging(ctx, "")
+	}
+	file := filepath.Join("github.com", "realglobe-Inc", "go-lib", "rglog", "logger", "logger_test.go")
+	if dum := fileHndl.Dump(); dum != strings.Repeat(file, 7) {
+		t.Fatal(dum)
+	}
+}
+
+// 受け取ったログを取っておくハンドラ。
+type recordHandler struct {
+	handler.Handler
+	recs []handler.Record
+}
+
+func newRecordHandler() *recordHandler {
+	return &recordHandler{Handler: handler.NewNopHandler()}
+}
+
+func (hndl *recordHandler) Output(rec handler.Record) {
+	hndl.recs = append(hndl.recs, rec)
+}
+
+func testLoggerRecordInfo(t *testing.T, mgr Manager) {
+	log := mgr.Logger("a/b/c")
+	log.SetLevel(level.ALL)
+	log.SetUseParent(false)
+	if log.Name() != "a/b/c" {
+		t.Fatal(log.Name())
+	}
+
+	hndl := newRecordHandler()
+	log.AddHandler("test", hndl)
+
+	childLog := mgr.Logger("a/b/c/d")
+	childLog.Info("test")
+	childLog.With("a", 1).Info("test")
+
+	if len(hndl.recs) != 2 {
+		t.Fatal(hndl.recs)
+	}
+	rec := hndl.recs[0]
+	if rec.LoggerName() != "a/b/c/d" {
+		t.Fatal(rec.LoggerName())
+	} else if !strings.HasSuffix(rec.Function(), ".testLoggerRecordInfo") {
+		t.Fatal(rec.Function())
+	} else if rec.Pid() != os.Getpid() {
+		t.Fatal(rec.Pid(), os.Getpid())
+	} else if host, _ := os.Hostname(); rec.Hostname() != host {
+		t.Fatal(rec.Hostname(), host)
+	} else if rec.Goroutine() == 0 {
+		t.Fatal(rec.Goroutine())
+	} else if rec.Sequence() >= hndl.recs[1].Sequence() {
+		t.Fatal(rec.Sequence(), hndl.recs[1].Sequence())
+	} else if rec.Goroutine() != hndl.recs[1].Goroutine() {
+		t.Fatal(rec.Goroutine(), hndl.recs[1].Goroutine())
+	}
+}
+
+func testLoggerErrors(t *testing.T, mgr Manager) {
+	log := mgr.Logger("a")
+	log.SetLevel(level.ALL)
+	log.SetUseParent(false)
+	hndl := newRecordHandler()
+	log.AddHandler("test", hndl)
+
+	err1 := erro.New("error 1")
+	err2 := errors.New("error 2")
+	log.Err("failed ", err1)
+	log.Errf("failed %v", err1)
+	log.Info("test", handler.Field{Key: "err", Value: err2})
+	log.Info("test")
+
+	if len(hndl.recs) != 4 {
+		t.Fatal(hndl.recs)
+	}
+	for i, msg := range []string{"failed error 1", "failed error 1"} {
+		rec := hndl.recs[i]
+		if rec.Message() != msg {
+			t.Error(i, rec.Message())
+		} else if errs := rec.Errors(); len(errs) != 1 || errs[0] != err1 {
+			t.Error(i, errs)
+		}
+	}
+	if errs := hndl.recs[2].Errors(); len(errs) != 1 || errs[0] != err2 {
+		t.Error(errs)
+	} else if errs := hndl.recs[3].Errors(); len(errs) != 0 {
+		t.Error(errs)
+	}
+
+	// 包まれたエラーや、With や context で付けた付加情報のエラーも抜き出す。
+	hndl.recs = nil
+	wrapped := fmt.Errorf("wrap: %w", err1)
+	log.Err("failed ", wrapped)
+	ctx := ContextWithFields(context.Background(), "ctx", err1)
+	log.With("with", err1).ErrContext(ctx, "failed")
+	if len(hndl.recs) != 2 {
+		t.Fatal(hndl.recs)
+	}
+	if rec := hndl.recs[0]; rec.Message() != "failed wrap: error 1" {
+		t.Error(rec.Message())
+	} else if errs := rec.Errors(); len(errs) != 1 || errs[0] != wrapped {
+		t.Error(errs)
+	}
+	rec := hndl.recs[1]
+	if errs := rec.Errors(); len(errs) != 2 || errs[0] != err1 || errs[1] != err1 {
+		t.Error(errs)
+	}
+	for _, field := range rec.Fields() {
+		if s := fmt.Sprint(field.Value); s != "error 1" {
+			t.Error(field.Key, s)
+		}
+	}
+}
+
+// Close した後に書き出されたら覚えておく。
+type closeCheckHandler struct {
+	handler.Handler
+	closed     int32
+	afterClose int32
+}
+
+func (hndl *closeCheckHandler) Output(rec handler.Record) {
+	if atomic.LoadInt32(&hndl.closed) != 0 {
+		atomic.StoreInt32(&hndl.afterClose, 1)
+	}
+}
+
+func (hndl *closeCheckHandler) Close() error {
+	atomic.StoreInt32(&hndl.closed, 1)
+	return nil
+}
+
+func testLoggerSetHandlers(t *testing.T, mgr Manager) {
+	parent := mgr.Logger("a")
+	parent.SetLevel(level.ALL)
+	log := mgr.Logger("a/b")
+	log.SetLevel(level.ALL)
+
+	hndl := &closeCheckHandler{Handler: handler.NewNopHandler()}
+	parent.AddHandler("old", hndl)
+	parent.AddHandler("old2", handler.NewNopHandler())
+
+	done := make(chan struct{})
+	var wg sync.WaitGroup
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for {
+				select {
+				case <-done:
+					return
+				default:
+				}
+				log.Info("a")
+			}
+		}()
+	}
+
+	all := []*closeCheckHandler{hndl}
+	for i := 0; i < 100; i++ {
+		newHndl := &closeCheckHandler{Handler: handler.NewNopHandler()}
+		all = append(all, newHndl)
+		olds := parent.SetHandlers(map[string]handler.Handler{"new": newHndl})
+		if i == 0 && len(olds) != 2 {
+			t.Error(olds)
+		}
+		for _, old := range olds {
+			old.Close()
+		}
+		if hndls := parent.Handlers(); len(hndls) != 1 || hndls["new"] != newHndl {
+			t.Error(hndls)
+		}
+	}
+	close(done)
+	wg.Wait()
+
+	for _, hndl := range all {
+		if atomic.LoadInt32(&hndl.afterClose) != 0 {
+			t.Fatal("output after close")
+		}
+	}
+}
+
+func testLoggerFatal(t *testing.T, mgr Manager) {
+	log := mgr.Logger("a")
+	log.SetLevel(level.ALL)
+	log.SetUseParent(false)
+	hndl := handler.NewFlushHandler(&bytes.Buffer{})
+	log.AddHandler("test", hndl)
+	recHndl := newRecordHandler()
+	log.AddHandler("record", recHndl)
+
+	exit := Exit
+	defer func() { Exit = exit }()
+	code := -1
+	Exit = func(c int) { code = c }
+
+	log.Fatal("fatal")
+	if code != 1 {
+		t.Fatal(code)
+	} else if len(recHndl.recs) != 1 || recHndl.recs[0].Level() != level.FATAL || recHndl.recs[0].Message() != "fatal" {
+		t.Fatal(recHndl.recs)
+	}
+
+	code = -1
+	log.Fatalf("fatal %d", 1)
+	if code != 1 {
+		t.Fatal(code)
+	} else if len(recHndl.recs) != 2 || recHndl.recs[1].Message() != "fatal 1" {
+		t.Fatal(recHndl.recs)
+	}
+}
+
+func testLoggerPanic(t *testing.T, mgr Manager) {
+	log := mgr.Logger("a")
+	log.SetLevel(level.ALL)
+	log.SetUseParent(false)
+	recHndl := newRecordHandler()
+	log.AddHandler("record", recHndl)
+
+	for _, f := range []func(){
+		func() { log.Panic("panic ", handler.Field{Key: "a", Value: 1}) },
+		func() { log.Panicf("panic %s", "") },
+	} {
+		func() {
+			defer func() {
+				if rcv := recover(); rcv != "panic " {
+					t.Fatal(rcv)
+				}
+			}()
+			f()
+		}()
+	}
+	if len(recHndl.recs) != 2 || recHndl.recs[0].Level() != level.FATAL || len(recHndl.recs[0].Fields()) != 1 {
+		t.Fatal(recHndl.recs)
+	}
+}
+
+// Flush, Close を数える。
+type countCloseHandler struct {
+	handler.Handler
+	flushed int32
+	closed  int32
+	err     error
+}
+
+func (hndl *countCloseHandler) Flush() {
+	atomic.AddInt32(&hndl.flushed, 1)
+}
+
+func (hndl *countCloseHandler) Close() error {
+	atomic.AddInt32(&hndl.closed, 1)
+	return hndl.err
+}
+
+func testManagerVModule(t *testing.T, mgr VModuleManager) {
+	log := mgr.Logger("a")
+	log.SetLevel(level.INFO)
+	log.SetUseParent(false)
+	hndl := handler.NewMemoryHandlerUsing(handler.LevelOnlyFormatter)
+	log.AddHandler("test", hndl)
+
+	mgr.SetVModule(VModule{{"logger/logger_test.go", level.DEBUG}})
+	log.Debug("debug")
+	log.Trace("trace")
+	logByHelper(log.With("a", 1), "helper")
+	if !log.IsLoggable(level.DEBUG) {
+		t.Error("not loggable")
+	}
+
+	// 他のファイルには効かない。
+	mgr.SetVModule(VModule{{"other.go", level.DEBUG}})
+	log.Debug("other")
+	if log.IsLoggable(level.DEBUG) {
+		t.Error("loggable")
+	}
+
+	// ハンドラの重要度はそのまま効く。
+	mgr.SetVModule(VModule{{"logger_test.go", level.ALL}})
+	hndl.SetLevel(level.DEBUG)
+	log.Debug("debug2")
+	log.Trace("trace2")
+
+	mgr.SetVModule(nil)
+	log.Debug("cleared")
+
+	if dump := hndl.Dump(); dump != "[DEB] debug\n[INF] helper a=1\n[DEB] debug2\n" {
+		t.Fatal(dump)
+	}
+}
+
+func testManagerClose(t *testing.T, mgr ClosableManager) {
+	shared := &countCloseHandler{Handler: handler.NewNopHandler()}
+	failing := &countCloseHandler{Handler: handler.NewNopHandler(), err: errors.New("close error")}
+	mgr.Logger("").AddHandler("shared", shared)
+	mgr.Logger("a").AddHandler("shared", shared)
+	mgr.Logger("a/b").AddHandler("shared2", shared)
+	mgr.Logger("a/b").AddHandler("failing", failing)
+
+	if err := mgr.Close(context.Background()); err == nil || !strings.Contains(err.Error(), "close error") {
+		t.Fatal(err)
+	} else if shared.flushed != 1 || shared.closed != 1 {
+		t.Fatal(shared.flushed, shared.closed)
+	} else if failing.closed != 1 {
+		t.Fatal(failing.closed)
+	}
+
+	for _, name := range mgr.Names() {
+		if hndls := mgr.Logger(name).Handlers(); len(hndls) != 0 {
+			t.Fatal(name, hndls)
+		}
+	}
+}
+
+func testManagerRunBatch(t *testing.T, mgr Manager) {
+	hndl := handler.NewMemoryHandlerUsing(handler.LevelOnlyFormatter)
+	old := handler.NewNopHandler()
+	mgr.Logger("a").AddHandler("old", old)
+
+	RunBatch(mgr, func(b Batch) {
+		b.SetLevel("a", level.DEBUG)
+		b.SetUseParent("a", false)
+		if hndls := b.Handlers("a"); len(hndls) != 1 || hndls["old"] != old {
+			t.Error(hndls)
+		}
+		if olds := b.SetHandlers("a", map[string]handler.Handler{"test": hndl}); len(olds) != 1 || olds["old"] != old {
+			t.Error(olds)
+		}
+		b.SetLevel("a/b", level.INFO)
+	})
+
+	a := mgr.Logger("a")
+	if a.Level() != level.DEBUG || a.UseParent() {
+		t.Error(a.Level(), a.UseParent())
+	} else if hndls := a.Handlers(); len(hndls) != 1 || hndls["test"] != hndl {
+		t.Error(hndls)
+	}
+	a.Debug("a")
+	log := mgr.Logger("a/b")
+	log.Debug("a/b")
+	log.Info("a/b")
+	if dump := hndl.Dump(); dump != "[DEB] a\n[INF] a/b\n" {
+		t.Error(dump)
+	}
+}
+
+// 最適化で具体的な型のメソッド呼び出しにならないように、変数を介して呼ぶ。
+var (
+	testAllocsLogger   Logger
+	testAllocsDebugger debugger
+)
+
+type debugger interface {
+	Debug(v ...interface{})
+	Debugf(format string, v ...interface{})
+}
+
+// 何もしない debugger。
+type nopDebugger struct{}
+
+func (nopDebugger) Debug(v ...interface{})                 {}
+func (nopDebugger) Debugf(format string, v ...interface{}) {}
+
+// Logger インターフェースと With で返したロガーを通しても、捨てられるログではメモリ確保しないか。
+// インターフェース経由で可変長引数のメソッドを呼ぶと、呼び出し側が引数のスライスをヒープに確保する。
+// それは何もしない実装でも同じなので、それより多く確保しないかを調べる。
+func testLoggerFilteredAllocs(t *testing.T, mgr Manager) {
+	log := mgr.Logger("a/b/c")
+	log.SetLevel(level.INFO)
+	log.AddHandler("test", handler.NewNopHandler())
+	mgr.Logger("a/b").SetLevel(level.WARN)
+	mgr.Logger("a/b").AddHandler("test", handler.NewNopHandler())
+
+	testAllocsDebugger = nopDebugger{}
+	base := testing.AllocsPerRun(100, func() {
+		testAllocsDebugger.Debug("test message")
+		testAllocsDebugger.Debugf("test %s", "message")
+	})
+
+	for _, log := range []Logger{log, log.With("id", 1)} {
+		testAllocsLogger = log
+		if n := testing.AllocsPerRun(100, func() {
+			testAllocsLogger.Debug("test message")
+			testAllocsLogger.Debugf("test %s", "message")
+		}); n > base {
+			t.Error(n, base)
+		}
+		if n := testing.AllocsPerRun(100, func() {
+			testAllocsLogger.Debug()
+			testAllocsLogger.IsLoggable(level.DEBUG)
+			if testAllocsLogger.IsLoggable(level.DEBUG) {
+				testAllocsLogger.Debug("test message")
+			}
+		}); n != 0 {
+			t.Error(n)
+		}
+	}
+}
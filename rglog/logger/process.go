@@ -0,0 +1,56 @@
+// Copyright 2015 realglobe, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package logger
+
+import (
+	"bytes"
+	"os"
+	"runtime"
+	"strconv"
+	"sync/atomic"
+)
+
+// ログに載せるプロセスの情報。
+
+var pid = os.Getpid()
+
+var hostname string
+
+func init() {
+	hostname, _ = os.Hostname()
+}
+
+// ログの通し番号。
+var sequence uint64
+
+func nextSequence() uint64 {
+	return atomic.AddUint64(&sequence, 1)
+}
+
+// 呼んだゴルーチンの ID を返す。
+// 公式には取得方法が無いので、スタックトレースの先頭の "goroutine {ID} [...]:" から読み取る。
+func goroutineId() uint64 {
+	var buff [64]byte
+	n := runtime.Stack(buff[:], false)
+	line := bytes.TrimPrefix(buff[:n], []byte("goroutine "))
+	if pos := bytes.IndexByte(line, ' '); pos >= 0 {
+		line = line[:pos]
+	}
+	id, err := strconv.ParseUint(string(line), 10, 64)
+	if err != nil {
+		return 0
+	}
+	return id
+}
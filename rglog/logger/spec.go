@@ -0,0 +1,130 @@
+// Copyright 2015 realglobe, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package logger
+
+import (
+	"path"
+	"strconv"
+	"strings"
+
+	"github.com/realglobe-Inc/go-lib/erro"
+	"github.com/realglobe-Inc/go-lib/rglog/level"
+)
+
+// ロガーごとの重要度をまとめて指定する。
+// "github.com/foo/bar=DEBUG,github.com/foo=WARN,=INFO" のような文字列で表す。
+// ロガー名には path.Match 形式のワイルドカードが使える。
+// ワイルドカードを含む指定は、NewLockLoggerManager の Manager なら後でつくられたロガーにも効く。
+// それ以外の Manager では、適用時に作成済みのロガーにだけ効く。
+// ハンドラを持たないロガーに指定した重要度は、先祖のハンドラに処理させるときの下限になる。
+type LevelSpec []*LevelRule
+
+// 1 つの指定。
+type LevelRule struct {
+	// ロガー名かそのパターン。
+	Pattern string
+	Level   level.Level
+}
+
+func (rule *LevelRule) String() string {
+	return rule.Pattern + "=" + rule.Level.String()
+}
+
+func (rule *LevelRule) isPattern() bool {
+	return strings.ContainsAny(rule.Pattern, `*?[\`)
+}
+
+// 文字列から LevelSpec をつくる。
+func ParseLevelSpec(s string) (LevelSpec, error) {
+	spec := LevelSpec{}
+	if strings.TrimSpace(s) == "" {
+		return spec, nil
+	}
+
+	for i, entry := range strings.Split(s, ",") {
+		pos := strings.LastIndex(entry, "=")
+		if pos < 0 {
+			return nil, erro.New("entry " + strconv.Itoa(i) + " " + strconv.Quote(entry) + " has no =")
+		}
+
+		pattern := strings.TrimSpace(entry[:pos])
+		if _, err := path.Match(pattern, ""); err != nil {
+			return nil, erro.New("entry " + strconv.Itoa(i) + " " + strconv.Quote(entry) + " has invalid pattern: " + err.Error())
+		}
+		lv, err := level.ValueOf(entry[pos+1:])
+		if err != nil {
+			return nil, erro.New("entry " + strconv.Itoa(i) + " " + strconv.Quote(entry) + " has invalid level: " + erro.Unwrap(err).Error())
+		}
+
+		spec = append(spec, &LevelRule{pattern, lv})
+	}
+	return spec, nil
+}
+
+func (spec LevelSpec) String() string {
+	buff := []string{}
+	for _, rule := range spec {
+		buff = append(buff, rule.String())
+	}
+	return strings.Join(buff, ",")
+}
+
+// flag.Value を実装。
+// 複数回指定されたら後ろに追加していく。
+func (spec *LevelSpec) Set(s string) error {
+	spec2, err := ParseLevelSpec(s)
+	if err != nil {
+		return erro.Wrap(err)
+	}
+	*spec = append(*spec, spec2...)
+	return nil
+}
+
+// 後でつくられるロガーにも重要度の指定を適用できる Manager。
+type levelRuleKeeper interface {
+	keepLevelRules(rules []*LevelRule)
+}
+
+// 前から順に重要度を設定する。
+// 同じロガーに複数の指定が当てはまったら、後の指定が勝つ。
+func (spec LevelSpec) Apply(mgr Manager) {
+	if keeper, ok := mgr.(levelRuleKeeper); ok {
+		// 適用中につくられたロガーを取りこぼさないように、先に覚えさせる。
+		patterns := []*LevelRule{}
+		for _, rule := range spec {
+			if rule.isPattern() {
+				patterns = append(patterns, rule)
+			}
+		}
+		keeper.keepLevelRules(patterns)
+	}
+
+	var names []string
+	for _, rule := range spec {
+		if !rule.isPattern() {
+			mgr.Logger(rule.Pattern).SetLevel(rule.Level)
+			continue
+		}
+
+		if names == nil {
+			names = mgr.Names()
+		}
+		for _, name := range names {
+			if ok, _ := path.Match(rule.Pattern, name); ok {
+				mgr.Logger(name).SetLevel(rule.Level)
+			}
+		}
+	}
+}
@@ -0,0 +1,131 @@
+// Copyright 2015 realglobe, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//	http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+package logger
+
+import (
+	"flag"
+	"io/ioutil"
+	"testing"
+
+	"github.com/realglobe-Inc/go-lib/rglog/handler"
+)
+
+func TestParseLevelSpec(t *testing.T) {
+	spec, err := ParseLevelSpec("github.com/foo/bar=DEBUG, github.com/foo=warn,=INFO,a/*=TRACE")
+	if err != nil {
+		t.Fatal(err)
+	} else if len(spec) != 4 {
+		t.Fatal(spec)
+	} else if s := spec.String(); s != "github.com/foo/bar=DEBUG,github.com/foo=WARN,=INFO,a/*=TRACE" {
+		t.Fatal(s)
+	}
+
+	if spec, err := ParseLevelSpec(" "); err != nil {
+		t.Fatal(err)
+	} else if len(spec) != 0 {
+		t.Fatal(spec)
+	}
+
+	for _, s := range []string{"a", "a=DEBUG,", "a=UNKO", "a[=DEBUG"} {
+		if spec, err := ParseLevelSpec(s); err == nil {
+			t.Fatal(s, spec)
+		}
+	}
+}
+
+func TestLevelSpecApply(t *testing.T) {
+	// 普通の使い方のように、ハンドラは "" にだけ付ける。
+	mgr := NewLockLoggerManager()
+	hndl := handler.NewMemoryHandlerUsing(handler.LevelOnlyFormatter)
+	mgr.Logger("").AddHandler("test", hndl)
+	mgr.Logger("a/b")
+	mgr.Logger("a/c")
+	mgr.Logger("a/c/d")
+
+	spec, err := ParseLevelSpec("a/*=DEBUG,a/c=WARN,x/y=ERR,=INFO")
+	if err != nil {
+		t.Fatal(err)
+	}
+	spec.Apply(mgr)
+
+	for _, c := range []struct {
+		name string
+		dump string
+	}{
+		{"", "[INF] info\n[WAR] warn\n[ERR] err\n"},
+		{"a", "[INF] info\n[WAR] warn\n[ERR] err\n"},
+		{"a/b", "[DEB] debug\n[INF] info\n[WAR] warn\n[ERR] err\n"},
+		{"a/c", "[WAR] warn\n[ERR] err\n"},
+		{"a/c/d", "[WAR] warn\n[ERR] err\n"},
+		{"x/y", "[ERR] err\n"},
+		{"x/y/z", "[ERR] err\n"},
+	} {
+		before := len(hndl.Dump())
+		log := mgr.Logger(c.name)
+		log.Debug("debug")
+		log.Info("info")
+		log.Warn("warn")
+		log.Err("err")
+		if dump := hndl.Dump()[before:]; dump != c.dump {
+			t.Error(c.name, dump)
+		}
+	}
+}
+
+// ワイルドカードの指定が、後でつくられたロガーにも効くか。
+func TestLevelSpecApplyLater(t *testing.T) {
+	mgr := NewLockLoggerManager()
+	hndl := handler.NewMemoryHandlerUsing(handler.LevelOnlyFormatter)
+	mgr.Logger("").AddHandler("test", hndl)
+
+	spec, err := ParseLevelSpec("=INFO,github.com/foo/*=DEBUG")
+	if err != nil {
+		t.Fatal(err)
+	}
+	spec.Apply(mgr)
+
+	mgr.Logger("github.com/foo/bar").Debug("debug")
+	mgr.Logger("github.com/baz").Debug("debug")
+	if dump := hndl.Dump(); dump != "[DEB] debug\n" {
+		t.Fatal(dump)
+	}
+
+	// 同じパターンは後の指定が勝つ。
+	spec, err = ParseLevelSpec("github.com/foo/*=WARN")
+	if err != nil {
+		t.Fatal(err)
+	}
+	spec.Apply(mgr)
+	mgr.Logger("github.com/foo/bar").Info("info")
+	mgr.Logger("github.com/foo/qux").Info("info")
+	if dump := hndl.Dump(); dump != "[DEB] debug\n" {
+		t.Fatal(dump)
+	}
+}
+
+func TestLevelSpecFlag(t *testing.T) {
+	flags := flag.NewFlagSet("test", flag.ContinueOnError)
+	flags.SetOutput(ioutil.Discard)
+	var spec LevelSpec
+	flags.Var(&spec, "log", "Log levels")
+	if err := flags.Parse([]string{"-log", "a=DEBUG", "-log", "b=WARN,c=ERR"}); err != nil {
+		t.Fatal(err)
+	} else if s := spec.String(); s != "a=DEBUG,b=WARN,c=ERR" {
+		t.Fatal(s)
+	}
+
+	if err := flags.Parse([]string{"-log", "a"}); err == nil {
+		t.Fatal("no error")
+	}
+}
@@ -0,0 +1,92 @@
+// Copyright 2015 realglobe, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package logger
+
+import (
+	"path/filepath"
+	"testing"
+
+	"github.com/realglobe-Inc/go-lib/rglog/level"
+)
+
+func TestParseVModule(t *testing.T) {
+	vmod, err := ParseVModule("handler/rotate*.go=DEBUG, lock.go=trace")
+	if err != nil {
+		t.Fatal(err)
+	} else if s := vmod.String(); s != "handler/rotate*.go=DEBUG,lock.go=TRACE" {
+		t.Fatal(s)
+	}
+
+	for _, s := range []string{"a.go", "=DEBUG", "a.go=UNKO", "a[.go=DEBUG"} {
+		if vmod, err := ParseVModule(s); err == nil {
+			t.Fatal(s, vmod)
+		}
+	}
+}
+
+func TestVModuleFileLevel(t *testing.T) {
+	vmod := VModule{{"handler/*.go", level.DEBUG}, {"rotate*.go", level.TRACE}}
+	for file, lv := range map[string]level.Level{
+		"a/handler/simple.go": level.DEBUG,
+		"a/handler/rotate.go": level.TRACE,
+		"a/logger/rotate.go":  level.TRACE,
+		"a/logger/lock.go":    level.OFF,
+	} {
+		if lv2 := vmod.fileLevel(file); lv2 != lv {
+			t.Error(file, lv2, lv)
+		}
+	}
+}
+
+// Helper で包んだ関数を通しても、包んだ関数を呼んだファイルで重要度の下限が決まるか。
+func TestVModuleHelper(t *testing.T) {
+	mgr := NewLockLoggerManager()
+	log := mgr.Logger("a")
+	log.SetLevel(level.INFO)
+	hndl := newRecordHandler()
+	log.AddHandler("test", hndl)
+
+	mgr.SetVModule(VModule{{"vmodule_test.go", level.DEBUG}})
+	debugByHelper(log, "caller")
+	// 包んだ関数のファイルは関係無い。
+	mgr.SetVModule(VModule{{"logger_test.go", level.DEBUG}})
+	debugByHelper(log, "helper")
+
+	if len(hndl.recs) != 1 {
+		t.Fatal(hndl.recs)
+	} else if rec := hndl.recs[0]; rec.Message() != "caller" || filepath.Base(rec.File()) != "vmodule_test.go" {
+		t.Fatal(rec.Message(), rec.File())
+	}
+}
+
+// 呼び出し元を覚えた後で Helper で登録されても、包んだ関数を呼んだファイルで重要度の下限が決まるか。
+func TestVModuleLateHelper(t *testing.T) {
+	mgr := NewLockLoggerManager()
+	log := mgr.Logger("a")
+	log.SetLevel(level.INFO)
+	hndl := newRecordHandler()
+	log.AddHandler("test", hndl)
+
+	mgr.SetVModule(VModule{{"vmodule_test.go", level.DEBUG}})
+	// 包んだ関数の中の呼び出し元を覚える。
+	debugByLateHelper(log, false, "before")
+	debugByLateHelper(log, true, "after")
+
+	if len(hndl.recs) != 1 {
+		t.Fatal(hndl.recs)
+	} else if rec := hndl.recs[0]; rec.Message() != "after" || filepath.Base(rec.File()) != "vmodule_test.go" {
+		t.Fatal(rec.Message(), rec.File())
+	}
+}
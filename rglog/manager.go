@@ -0,0 +1,90 @@
+// Copyright 2015 realglobe, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package rglog
+
+import (
+	"sync/atomic"
+
+	"github.com/realglobe-Inc/go-lib/rglog/handler"
+	"github.com/realglobe-Inc/go-lib/rglog/level"
+	"github.com/realglobe-Inc/go-lib/rglog/logger"
+)
+
+// 大域の Manager と、Configure 等で使うその設定機。
+type global struct {
+	mgr     logger.Manager
+	conftor *Configurator
+}
+
+// *global.
+var glb atomic.Value
+
+func init() {
+	SetManager(NewDefaultManager())
+}
+
+func current() *global {
+	return glb.Load().(*global)
+}
+
+// 何も設定していない Manager をつくる。
+// 全てのロガーの重要度は level.OFF で、ハンドラも無い。
+// 他から切り離したロガーの木が欲しいときに使う。
+func NewManager() logger.Manager {
+	return logger.NewLockLoggerManager()
+}
+
+// 無設定時の動作を設定した Manager をつくる。
+// "" のロガーが level.INFO 以上を標準エラー出力に書き出す。
+func NewDefaultManager() logger.Manager {
+	m := NewManager()
+
+	log := m.Logger("")
+	log.SetLevel(level.INFO)
+	log.SetUseParent(false)
+
+	hndl := handler.NewConsoleHandler()
+	hndl.SetLevel(level.ALL)
+	log.AddHandler("console", hndl)
+	return m
+}
+
+// 大域の Manager を返す。
+// rglog.Logger, rglog.Configure 等はこれを使う。
+func Manager() logger.Manager {
+	return current().mgr
+}
+
+// 大域の Manager を m に置き換えて、元の Manager を返す。
+// rglog.Logger で取得済みのロガーも m のロガーになる。
+// 元の Manager の Logger で取得したロガーと、ReloadOnSignal, WatchConfigFile で始めた再読み込みは元の Manager のまま。
+// 元の Manager は Close しないので、要らなければ呼び出し側で Close する。
+// m が nil なら panic する。
+//
+//	defer rglog.SetManager(rglog.SetManager(rglog.NewManager()))
+func SetManager(m logger.Manager) (old logger.Manager) {
+	if m == nil {
+		panic("nil Manager")
+	}
+	if prev, ok := glb.Swap(&global{m, NewConfigurator(m)}).(*global); ok {
+		return prev.mgr
+	}
+	return nil
+}
+
+// 大域の Manager を NewDefaultManager() に置き換えて、元の Manager を返す。
+func ResetManager() (old logger.Manager) {
+	return SetManager(NewDefaultManager())
+}
@@ -0,0 +1,148 @@
+// Copyright 2015 realglobe, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package rglog
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/realglobe-Inc/go-lib/rglog/handler"
+	"github.com/realglobe-Inc/go-lib/rglog/level"
+	"github.com/realglobe-Inc/go-lib/rglog/logger"
+)
+
+func TestNewManager(t *testing.T) {
+	m := NewManager()
+	if log := m.Logger(""); log.Level() != level.OFF || len(log.Handlers()) != 0 {
+		t.Fatal(log.Level(), log.Handlers())
+	}
+
+	m = NewDefaultManager()
+	if log := m.Logger(""); log.Level() != level.INFO || log.UseParent() || log.Handler("console") == nil {
+		t.Fatal(log.Level(), log.UseParent(), log.Handlers())
+	}
+}
+
+func TestSetManager(t *testing.T) {
+	orig := Manager()
+	m := NewManager()
+	if old := SetManager(m); old != orig {
+		t.Fatal(old, orig)
+	}
+	defer SetManager(orig)
+
+	hndl := handler.NewMemoryHandlerUsing(handler.LevelOnlyFormatter)
+	root := m.Logger("")
+	root.SetLevel(level.INFO)
+	root.AddHandler("test", hndl)
+
+	if Manager() != m {
+		t.Fatal(Manager())
+	}
+	Logger("a").Info("abc")
+	if err := Configure(strings.NewReader(`{"loggers": {"a": {"level": "DEBUG"}}}`)); err != nil {
+		t.Fatal(err)
+	} else if lv := m.Logger("a").Level(); lv != level.DEBUG {
+		t.Fatal(lv)
+	} else if lv := orig.Logger("a").Level(); lv == level.DEBUG {
+		t.Fatal(lv)
+	}
+	if dump := hndl.Dump(); dump != "[INF] abc\n" {
+		t.Fatal(dump)
+	}
+
+	if old := ResetManager(); old != m {
+		t.Fatal(old, m)
+	} else if Manager() == m || Manager().Logger("").Handler("console") == nil {
+		t.Fatal(Manager())
+	} else if Manager().Logger("a").Level() != level.OFF {
+		t.Fatal(Manager().Logger("a").Level())
+	}
+}
+
+// 置き換える前に取得したロガーも、置き換えた Manager を使うか。
+func TestSetManagerPackageLogger(t *testing.T) {
+	log := Logger("a")
+
+	m := NewManager()
+	defer SetManager(SetManager(m))
+	hndl := handler.NewMemoryHandlerUsing(handler.LevelOnlyFormatter)
+	root := m.Logger("")
+	root.SetLevel(level.INFO)
+	root.AddHandler("test", hndl)
+
+	log.Info("abc")
+	if dump := hndl.Dump(); dump != "[INF] abc\n" {
+		t.Fatal(dump)
+	}
+
+	SetManager(NewManager())
+	log.Info("def")
+	if dump := hndl.Dump(); dump != "[INF] abc\n" {
+		t.Fatal(dump)
+	}
+}
+
+func TestSetManagerNil(t *testing.T) {
+	orig := Manager()
+	defer func() {
+		if rcv := recover(); rcv == nil {
+			t.Fatal("no panic")
+		} else if Manager() != orig {
+			t.Fatal(Manager())
+		}
+	}()
+	SetManager(nil)
+}
+
+func TestSetVModule(t *testing.T) {
+	m := NewManager()
+	defer SetManager(SetManager(m))
+
+	hndl := handler.NewMemoryHandlerUsing(handler.LevelOnlyFormatter)
+	log := m.Logger("a")
+	log.SetLevel(level.INFO)
+	log.AddHandler("test", hndl)
+
+	if err := SetVModule("rglog/manager_test.go=DEBUG"); err != nil {
+		t.Fatal(err)
+	}
+	log.Debug("debug")
+	if err := SetVModule(""); err != nil {
+		t.Fatal(err)
+	}
+	log.Debug("cleared")
+	if err := SetVModule("a.go"); err == nil {
+		t.Fatal("no error")
+	}
+
+	if dump := hndl.Dump(); dump != "[DEB] debug\n" {
+		t.Fatal(dump)
+	}
+}
+
+// SetVModule を持たない Manager ではエラーになるか。
+func TestSetVModuleUnsupported(t *testing.T) {
+	defer SetManager(SetManager(&plainManager{NewManager()}))
+
+	if err := SetVModule("a.go=DEBUG"); err == nil {
+		t.Fatal("no error")
+	}
+}
+
+// logger.Manager のメソッドだけを持つ Manager。
+type plainManager struct {
+	logger.Manager
+}
@@ -0,0 +1,284 @@
+// Copyright 2015 realglobe, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package rglog
+
+import (
+	"os"
+	"os/signal"
+	"reflect"
+	"strconv"
+	"sync"
+	"syscall"
+	"time"
+
+	"github.com/realglobe-Inc/go-lib/erro"
+	"github.com/realglobe-Inc/go-lib/rglog/handler"
+	"github.com/realglobe-Inc/go-lib/rglog/logger"
+)
+
+// 設定し直しのエラー等を記録するロガーの名前。
+const reloadLoggerName = "github.com/realglobe-Inc/go-lib/rglog"
+
+// 設定を反映させ直す。
+// 前回反映させた設定との差分を取って、
+// 設定の変わらないハンドラはそのまま使い、要らなくなったハンドラは Flush して Close する。
+// 前回の設定にあって今回の設定に無いロガーからは、前回の設定で登録したハンドラを外す。重要度等はそのまま。
+// handlers を省いたロガーでは、前回の設定で登録したハンドラを、設定が変わっていれば差し替え、無くなっていれば外す。
+// Manager が logger.BatchManager なら、全てのロガーの変更を一度に反映させる。
+type Configurator struct {
+	lock sync.Mutex
+	mgr  logger.Manager
+
+	// 前回反映させた設定と、そのときのハンドラ。
+	conf  *Config
+	hndls map[string]handler.Handler
+	// ロガーごとの、この Configurator が登録したハンドラの名前。
+	attached map[string][]string
+}
+
+func NewConfigurator(mgr logger.Manager) *Configurator {
+	return &Configurator{
+		mgr:      mgr,
+		conf:     &Config{},
+		hndls:    map[string]handler.Handler{},
+		attached: map[string][]string{},
+	}
+}
+
+// 設定を反映させる。
+// エラーのときは何も変わらない。
+func (conftor *Configurator) Apply(conf *Config) error {
+	conftor.lock.Lock()
+	defer conftor.lock.Unlock()
+
+	for _, name := range sortedKeys(conf.Loggers) {
+		for _, hndlName := range conf.Loggers[name].Handlers {
+			if _, ok := conf.Handlers[hndlName]; !ok {
+				return erro.New("loggers." + strconv.Quote(name) + ".handlers: handler " + strconv.Quote(hndlName) + " is not exist")
+			}
+		}
+	}
+
+	hndls, err := conftor.newHandlers(conf)
+	if err != nil {
+		return erro.Wrap(err)
+	}
+
+	attached := map[string][]string{}
+	logger.RunBatch(conftor.mgr, func(b logger.Batch) {
+		for _, name := range sortedKeys(conf.Loggers) {
+			logConf := conf.Loggers[name]
+			if logConf.Level != nil {
+				b.SetLevel(name, *logConf.Level)
+			}
+			if logConf.UseParent != nil {
+				b.SetUseParent(name, *logConf.UseParent)
+			}
+			if logConf.Handlers != nil {
+				logHndls := map[string]handler.Handler{}
+				for _, hndlName := range logConf.Handlers {
+					logHndls[hndlName] = hndls[hndlName]
+				}
+				b.SetHandlers(name, logHndls)
+				attached[name] = logConf.Handlers
+			} else if len(conftor.attached[name]) > 0 {
+				attached[name] = conftor.reattach(b, name, hndls)
+			}
+		}
+		for _, name := range sortedAttachedKeys(conftor.attached) {
+			if _, ok := conf.Loggers[name]; !ok {
+				conftor.reattach(b, name, nil)
+			}
+		}
+	})
+
+	// RunBatch が返った後なので、外したハンドラにはもう書き出されない。
+	for name, old := range conftor.hndls {
+		if hndls[name] != old {
+			old.Flush()
+			if err := old.Close(); err != nil {
+				conftor.mgr.Logger(reloadLoggerName).Err("Closing log handler "+name+" failed: ", erro.Unwrap(err))
+			}
+		}
+	}
+
+	conftor.conf = conf
+	conftor.hndls = hndls
+	conftor.attached = attached
+	return nil
+}
+
+// 前回 name のロガーに登録したハンドラを、hndls の同じ名前のハンドラに差し替える。
+// hndls に無ければ外す。他で差し替えられていたら触らない。
+// 差し替えたハンドラの名前を返す。
+func (conftor *Configurator) reattach(b logger.Batch, name string, hndls map[string]handler.Handler) []string {
+	logHndls := b.Handlers(name)
+	names := []string{}
+	for _, hndlName := range conftor.attached[name] {
+		if logHndls[hndlName] != conftor.hndls[hndlName] {
+			continue
+		}
+		if hndl := hndls[hndlName]; hndl != nil {
+			logHndls[hndlName] = hndl
+			names = append(names, hndlName)
+		} else {
+			delete(logHndls, hndlName)
+		}
+	}
+	b.SetHandlers(name, logHndls)
+	return names
+}
+
+// 前回と設定が同じハンドラは使い回す。
+// エラーのときは、新しくつくったハンドラを閉じる。
+func (conftor *Configurator) newHandlers(conf *Config) (map[string]handler.Handler, error) {
+	hndls := map[string]handler.Handler{}
+	created := []handler.Handler{}
+	for _, name := range sortedHandlerKeys(conf.Handlers) {
+		hndlConf := conf.Handlers[name]
+		if old := conftor.hndls[name]; old != nil && reflect.DeepEqual(conftor.conf.Handlers[name], hndlConf) {
+			hndls[name] = old
+			continue
+		}
+
+		hndl, err := newHandler("handlers."+strconv.Quote(name), hndlConf)
+		if err != nil {
+			for _, hndl := range created {
+				hndl.Close()
+			}
+			return nil, erro.Wrap(err)
+		}
+		hndls[name] = hndl
+		created = append(created, hndl)
+	}
+	return hndls, nil
+}
+
+// JSON の設定ファイルを読んで、反映させる。
+func (conftor *Configurator) ApplyFile(path string) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return erro.Wrap(err)
+	}
+	defer f.Close()
+
+	conf, err := ParseConfig(f)
+	if err != nil {
+		return erro.New(path+": ", err)
+	}
+	return conftor.Apply(conf)
+}
+
+// path の設定ファイルを読み直して反映させる。
+// 失敗したら、設定はそのままにして、エラーをログに残す。
+func (conftor *Configurator) reload(path string) {
+	log := conftor.mgr.Logger(reloadLoggerName)
+	if err := conftor.ApplyFile(path); err != nil {
+		log.Err("Reloading log config failed: ", erro.Unwrap(err))
+		log.Debug(err)
+		return
+	}
+	log.Info("Reloaded log config " + path)
+}
+
+// シグナルを受け取る度に、設定ファイルを読み直して反映させる。
+// sigs を指定しなければ SIGHUP。
+// 返り値の関数で止める。
+func (conftor *Configurator) ReloadOnSignal(path string, sigs ...os.Signal) (stop func()) {
+	if len(sigs) == 0 {
+		sigs = []os.Signal{syscall.SIGHUP}
+	}
+	sigCh := make(chan os.Signal, 1)
+	signal.Notify(sigCh, sigs...)
+
+	done := make(chan struct{})
+	go func() {
+		for {
+			select {
+			case <-done:
+				return
+			case <-sigCh:
+				conftor.reload(path)
+			}
+		}
+	}()
+
+	var once sync.Once
+	return func() {
+		once.Do(func() {
+			signal.Stop(sigCh)
+			close(done)
+		})
+	}
+}
+
+// interval ごとに設定ファイルを調べて、変更されていたら読み直して反映させる。
+// 返り値の関数で止める。
+func (conftor *Configurator) WatchFile(path string, interval time.Duration) (stop func()) {
+	modTime, size := fileStamp(path)
+
+	done := make(chan struct{})
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-done:
+				return
+			case <-ticker.C:
+			}
+
+			curModTime, curSize := fileStamp(path)
+			if curModTime.Equal(modTime) && curSize == size {
+				continue
+			}
+			modTime, size = curModTime, curSize
+			conftor.reload(path)
+		}
+	}()
+
+	var once sync.Once
+	return func() {
+		once.Do(func() { close(done) })
+	}
+}
+
+// ファイルが無ければゼロ値。
+func fileStamp(path string) (time.Time, int64) {
+	fi, err := os.Stat(path)
+	if err != nil {
+		return time.Time{}, -1
+	}
+	return fi.ModTime(), fi.Size()
+}
+
+// JSON の設定ファイルを読んで、設定する。
+// 2 回目以降は前回との差分を反映させる。
+func ConfigureFile(path string) error {
+	return current().conftor.ApplyFile(path)
+}
+
+// シグナルを受け取る度に、設定ファイルを読み直して反映させる。
+// sigs を指定しなければ SIGHUP。
+// 返り値の関数で止める。
+func ReloadOnSignal(path string, sigs ...os.Signal) (stop func()) {
+	return current().conftor.ReloadOnSignal(path, sigs...)
+}
+
+// interval ごとに設定ファイルを調べて、変更されていたら読み直して反映させる。
+// 返り値の関数で止める。
+func WatchConfigFile(path string, interval time.Duration) (stop func()) {
+	return current().conftor.WatchFile(path, interval)
+}
@@ -0,0 +1,291 @@
+// Copyright 2015 realglobe, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package rglog
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/realglobe-Inc/go-lib/rglog/handler"
+	"github.com/realglobe-Inc/go-lib/rglog/level"
+	"github.com/realglobe-Inc/go-lib/rglog/logger"
+)
+
+// Close されたかどうかを覚えておくハンドラ。
+type testCloseHandler struct {
+	*handler.MemoryHandler
+	closed bool
+}
+
+func (hndl *testCloseHandler) Close() error {
+	testCloseHndls.lock.Lock()
+	defer testCloseHndls.lock.Unlock()
+	hndl.closed = true
+	return nil
+}
+
+func (hndl *testCloseHandler) isClosed() bool {
+	testCloseHndls.lock.Lock()
+	defer testCloseHndls.lock.Unlock()
+	return hndl.closed
+}
+
+var testCloseHndls = struct {
+	lock  sync.Mutex
+	hndls map[string]*testCloseHandler
+}{hndls: map[string]*testCloseHandler{}}
+
+func getTestCloseHandler(id string) *testCloseHandler {
+	testCloseHndls.lock.Lock()
+	defer testCloseHndls.lock.Unlock()
+	return testCloseHndls.hndls[id]
+}
+
+func init() {
+	// "id"。
+	RegisterHandlerFactory("test-close", func(params *HandlerParams) (handler.Handler, error) {
+		id, err := params.RequiredString("id")
+		if err != nil {
+			return nil, err
+		}
+		hndl := &testCloseHandler{MemoryHandler: handler.NewMemoryHandler()}
+		testCloseHndls.lock.Lock()
+		testCloseHndls.hndls[id] = hndl
+		testCloseHndls.lock.Unlock()
+		return hndl, nil
+	})
+}
+
+func parseTestConfig(t *testing.T, s string) *Config {
+	conf, err := ParseConfig(strings.NewReader(s))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return conf
+}
+
+func TestConfiguratorReload(t *testing.T) {
+	mgr := logger.NewLockLoggerManager()
+	conftor := NewConfigurator(mgr)
+	if err := conftor.Apply(parseTestConfig(t, `{
+  "loggers": {
+    "a": {"level": "INFO", "handlers": ["keep", "change"]},
+    "b": {"handlers": ["remove"]}
+  },
+  "handlers": {
+    "keep": {"type": "test-close", "id": "reload-keep"},
+    "change": {"type": "test-close", "id": "reload-change"},
+    "remove": {"type": "test-close", "id": "reload-remove"}
+  }
+}`)); err != nil {
+		t.Fatal(err)
+	}
+	keep := getTestCloseHandler("reload-keep")
+	change := getTestCloseHandler("reload-change")
+	remove := getTestCloseHandler("reload-remove")
+	mgr.Logger("b").AddHandler("other", handler.NewNopHandler())
+
+	if err := conftor.Apply(parseTestConfig(t, `{
+  "loggers": {
+    "a": {"level": "DEBUG", "handlers": ["keep", "change"]}
+  },
+  "handlers": {
+    "keep": {"type": "test-close", "id": "reload-keep"},
+    "change": {"type": "test-close", "id": "reload-change2"}
+  }
+}`)); err != nil {
+		t.Fatal(err)
+	}
+
+	if keep.isClosed() {
+		t.Error("unchanged handler is closed")
+	} else if !change.isClosed() {
+		t.Error("changed handler is not closed")
+	} else if !remove.isClosed() {
+		t.Error("removed handler is not closed")
+	}
+
+	a := mgr.Logger("a")
+	if a.Level() != level.DEBUG {
+		t.Error(a.Level())
+	} else if hndls := a.Handlers(); hndls["keep"] != keep || hndls["change"] != getTestCloseHandler("reload-change2") {
+		t.Error(hndls)
+	}
+	if hndls := mgr.Logger("b").Handlers(); len(hndls) != 1 || hndls["other"] == nil {
+		t.Error(hndls)
+	}
+}
+
+// handlers を省いても、前回登録したハンドラが差し替えられたり外されたりするか。
+func TestConfiguratorReloadDropHandlers(t *testing.T) {
+	mgr := logger.NewLockLoggerManager()
+	conftor := NewConfigurator(mgr)
+	if err := conftor.Apply(parseTestConfig(t, `{
+  "loggers": {
+    "a": {"level": "INFO", "useParent": false, "handlers": ["keep", "change", "remove"]}
+  },
+  "handlers": {
+    "keep": {"type": "test-close", "id": "drop-keep"},
+    "change": {"type": "test-close", "id": "drop-change"},
+    "remove": {"type": "test-close", "id": "drop-remove"}
+  }
+}`)); err != nil {
+		t.Fatal(err)
+	}
+	keep := getTestCloseHandler("drop-keep")
+	change := getTestCloseHandler("drop-change")
+	remove := getTestCloseHandler("drop-remove")
+	other := handler.NewNopHandler()
+	mgr.Logger("a").AddHandler("other", other)
+
+	if err := conftor.Apply(parseTestConfig(t, `{
+  "loggers": {
+    "a": {"level": "DEBUG"}
+  },
+  "handlers": {
+    "keep": {"type": "test-close", "id": "drop-keep"},
+    "change": {"type": "test-close", "id": "drop-change2"}
+  }
+}`)); err != nil {
+		t.Fatal(err)
+	}
+	change2 := getTestCloseHandler("drop-change2")
+
+	if keep.isClosed() || change2.isClosed() {
+		t.Error("used handler is closed")
+	} else if !change.isClosed() || !remove.isClosed() {
+		t.Error("unused handler is not closed")
+	}
+	a := mgr.Logger("a")
+	if hndls := a.Handlers(); len(hndls) != 3 || hndls["keep"] != keep || hndls["change"] != change2 || hndls["other"] != other {
+		t.Error(hndls)
+	}
+	// 閉じたハンドラには書き出されない。
+	a.Info("test")
+	if dump := change.Dump(); dump != "" {
+		t.Error(dump)
+	} else if dump := change2.Dump(); !strings.Contains(dump, "test") {
+		t.Error(dump)
+	}
+
+	// 差し替えたハンドラも、ロガーが設定から消えたら外す。
+	if err := conftor.Apply(parseTestConfig(t, `{
+  "handlers": {
+    "keep": {"type": "test-close", "id": "drop-keep"}
+  }
+}`)); err != nil {
+		t.Fatal(err)
+	}
+	if !change2.isClosed() {
+		t.Error("unused handler is not closed")
+	}
+	if hndls := a.Handlers(); len(hndls) != 1 || hndls["other"] != other {
+		t.Error(hndls)
+	}
+}
+
+func TestConfiguratorReloadError(t *testing.T) {
+	mgr := logger.NewLockLoggerManager()
+	conftor := NewConfigurator(mgr)
+	if err := conftor.Apply(parseTestConfig(t, `{
+  "loggers": {"a": {"level": "INFO", "handlers": ["a"]}},
+  "handlers": {"a": {"type": "test-close", "id": "reload-error"}}
+}`)); err != nil {
+		t.Fatal(err)
+	}
+	hndl := getTestCloseHandler("reload-error")
+
+	if err := conftor.Apply(parseTestConfig(t, `{
+  "loggers": {"a": {"level": "DEBUG", "handlers": ["a"]}},
+  "handlers": {"a": {"type": "test-close"}}
+}`)); err == nil {
+		t.Fatal("no error")
+	}
+
+	if hndl.isClosed() {
+		t.Error("handler is closed")
+	} else if log := mgr.Logger("a"); log.Level() != level.INFO || log.Handlers()["a"] != hndl {
+		t.Error(log.Level(), log.Handlers())
+	}
+}
+
+func writeTestConfig(t *testing.T, path string, lv string) {
+	if err := ioutil.WriteFile(path, []byte(`{"loggers": {"a": {"level": "`+lv+`"}}}`), 0644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func waitLevel(t *testing.T, log logger.Logger, lv level.Level) {
+	for i := 0; i < 500; i++ {
+		if log.Level() == lv {
+			return
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	t.Fatal(log.Level())
+}
+
+func TestConfiguratorWatchFile(t *testing.T) {
+	dir, err := ioutil.TempDir("", "rglog")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "log.json")
+	writeTestConfig(t, path, "INFO")
+
+	mgr := logger.NewLockLoggerManager()
+	conftor := NewConfigurator(mgr)
+	if err := conftor.ApplyFile(path); err != nil {
+		t.Fatal(err)
+	}
+	stop := conftor.WatchFile(path, 10*time.Millisecond)
+	defer stop()
+
+	writeTestConfig(t, path, "DEBUG")
+	waitLevel(t, mgr.Logger("a"), level.DEBUG)
+}
+
+func TestConfiguratorReloadOnSignal(t *testing.T) {
+	dir, err := ioutil.TempDir("", "rglog")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "log.json")
+	writeTestConfig(t, path, "INFO")
+
+	mgr := logger.NewLockLoggerManager()
+	conftor := NewConfigurator(mgr)
+	stop := conftor.ReloadOnSignal(path, syscall.SIGUSR1)
+	defer stop()
+
+	if err := syscall.Kill(os.Getpid(), syscall.SIGUSR1); err != nil {
+		t.Fatal(err)
+	}
+	waitLevel(t, mgr.Logger("a"), level.INFO)
+
+	writeTestConfig(t, path, "DEBUG")
+	if err := syscall.Kill(os.Getpid(), syscall.SIGUSR1); err != nil {
+		t.Fatal(err)
+	}
+	waitLevel(t, mgr.Logger("a"), level.DEBUG)
+}
@@ -0,0 +1,276 @@
+// Copyright 2015 realglobe, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// テストでログを取って調べるための道具。
+//
+//	capt := rglogtest.New(t)
+//	f(capt.Logger("a/b"))
+//	capt.Expect(rglogtest.Level(level.ERR), rglogtest.Message("^cannot open "))
+//	capt.ExpectNoErrors()
+package rglogtest
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"reflect"
+	"regexp"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/realglobe-Inc/go-lib/rglog/handler"
+	"github.com/realglobe-Inc/go-lib/rglog/level"
+	"github.com/realglobe-Inc/go-lib/rglog/logger"
+)
+
+// ログを溜めて調べるハンドラ。
+// 専用の Manager の "" のロガーに付いている。
+// スレッドセーフ。
+type Capture struct {
+	lock sync.Mutex
+	lv   level.Level
+	recs []handler.Record
+
+	t   testing.TB
+	mgr logger.Manager
+}
+
+// 他から切り離した Manager をつくり、その "" のロガーに Capture と NewTBHandler(t) を付ける。
+// "" のロガーの重要度は level.ALL。
+// テストが終わると Manager を Close する。
+func New(t testing.TB) *Capture {
+	capt := &Capture{lv: level.ALL, t: t, mgr: logger.NewLockLoggerManager()}
+
+	root := capt.mgr.Logger("")
+	root.SetLevel(level.ALL)
+	root.SetUseParent(false)
+	root.AddHandler("capture", capt)
+	root.AddHandler("test", NewTBHandler(t))
+
+	t.Cleanup(func() {
+		logger.CloseManager(context.Background(), capt.mgr)
+	})
+	return capt
+}
+
+// 専用の Manager。
+func (capt *Capture) Manager() logger.Manager {
+	return capt.mgr
+}
+
+// 専用の Manager のロガー。
+func (capt *Capture) Logger(name string) logger.Logger {
+	return capt.mgr.Logger(name)
+}
+
+// 溜まっているレコード。
+func (capt *Capture) Records() []handler.Record {
+	capt.lock.Lock()
+	defer capt.lock.Unlock()
+
+	return append([]handler.Record(nil), capt.recs...)
+}
+
+// 溜まっているレコードを捨てる。
+func (capt *Capture) Reset() {
+	capt.lock.Lock()
+	defer capt.lock.Unlock()
+
+	capt.recs = nil
+}
+
+// 全ての条件に合うレコードを返す。
+func (capt *Capture) Find(conds ...Cond) []handler.Record {
+	var recs []handler.Record
+	for _, rec := range capt.Records() {
+		if matchAll(rec, conds) {
+			recs = append(recs, rec)
+		}
+	}
+	return recs
+}
+
+// 全ての条件に合うレコードがあることを確かめる。
+// 最初に合ったレコードを返す。無ければテストを失敗にして nil を返す。
+func (capt *Capture) Expect(conds ...Cond) handler.Record {
+	capt.t.Helper()
+
+	if recs := capt.Find(conds...); len(recs) > 0 {
+		return recs[0]
+	}
+	capt.t.Errorf("no log record matches %v\n%s", conds, capt.dump())
+	return nil
+}
+
+// 全ての条件に合うレコードが無いことを確かめる。
+func (capt *Capture) ExpectNone(conds ...Cond) {
+	capt.t.Helper()
+
+	if recs := capt.Find(conds...); len(recs) > 0 {
+		capt.t.Errorf("%d log records match %v\n%s", len(recs), conds, format(recs))
+	}
+}
+
+// level.ERR 以上のレコードも、エラーを含むレコードも無いことを確かめる。
+func (capt *Capture) ExpectNoErrors() {
+	capt.t.Helper()
+
+	var recs []handler.Record
+	for _, rec := range capt.Records() {
+		if !rec.Level().Lower(level.ERR) || len(rec.Errors()) > 0 {
+			recs = append(recs, rec)
+		}
+	}
+	if len(recs) > 0 {
+		capt.t.Errorf("%d log records have errors\n%s", len(recs), format(recs))
+	}
+}
+
+func (capt *Capture) dump() string {
+	recs := capt.Records()
+	if len(recs) == 0 {
+		return "no log records"
+	}
+	return "log records:\n" + format(recs)
+}
+
+func format(recs []handler.Record) string {
+	var buff bytes.Buffer
+	for _, rec := range recs {
+		buff.Write(handler.SimpleFormatter.Format(rec))
+	}
+	return buff.String()
+}
+
+func (capt *Capture) Level() level.Level {
+	capt.lock.Lock()
+	defer capt.lock.Unlock()
+
+	return capt.lv
+}
+
+func (capt *Capture) SetLevel(lv level.Level) {
+	capt.lock.Lock()
+	defer capt.lock.Unlock()
+
+	capt.lv = lv
+}
+
+func (capt *Capture) Output(rec handler.Record) {
+	capt.lock.Lock()
+	defer capt.lock.Unlock()
+
+	if !rec.Level().Lower(capt.lv) {
+		capt.recs = append(capt.recs, rec)
+	}
+}
+
+func (capt *Capture) Flush() {}
+
+func (capt *Capture) Close() error {
+	return nil
+}
+
+// レコードの条件。
+type Cond struct {
+	desc  string
+	match func(rec handler.Record) bool
+}
+
+func (cond Cond) String() string {
+	return cond.desc
+}
+
+func matchAll(rec handler.Record, conds []Cond) bool {
+	for _, cond := range conds {
+		if !cond.match(rec) {
+			return false
+		}
+	}
+	return true
+}
+
+// 重要度が lv であるという条件。
+func Level(lv level.Level) Cond {
+	return Cond{"level=" + lv.String(), func(rec handler.Record) bool {
+		return rec.Level() == lv
+	}}
+}
+
+// 重要度が lv 以上であるという条件。
+func LevelAtLeast(lv level.Level) Cond {
+	return Cond{"level>=" + lv.String(), func(rec handler.Record) bool {
+		return !rec.Level().Lower(lv)
+	}}
+}
+
+// メッセージが正規表現 pattern に合うという条件。
+// pattern が正規表現として不正なら panic する。
+func Message(pattern string) Cond {
+	re := regexp.MustCompile(pattern)
+	return Cond{"message=~" + pattern, func(rec handler.Record) bool {
+		return re.MatchString(rec.Message())
+	}}
+}
+
+// 付加情報 key の値が val であるという条件。
+// 値は reflect.DeepEqual で比べる。
+func Field(key string, val interface{}) Cond {
+	return Cond{fmt.Sprintf("%s=%v", key, val), func(rec handler.Record) bool {
+		for _, field := range rec.Fields() {
+			if field.Key == key && reflect.DeepEqual(field.Value, val) {
+				return true
+			}
+		}
+		return false
+	}}
+}
+
+// ロガー名が name であるという条件。
+func LoggerName(name string) Cond {
+	return Cond{"logger=" + name, func(rec handler.Record) bool {
+		return rec.LoggerName() == name
+	}}
+}
+
+// エラーを含むという条件。
+func HasError() Cond {
+	return Cond{"errors", func(rec handler.Record) bool {
+		return len(rec.Errors()) > 0
+	}}
+}
+
+// t.Log で書き出すハンドラを返す。
+// go test では失敗したテストか -v のときだけ表示される。
+// テストが終わった後の Output は捨てる。
+func NewTBHandler(t testing.TB) handler.Handler {
+	return NewTBHandlerUsing(t, handler.SimpleFormatter)
+}
+
+func NewTBHandlerUsing(t testing.TB, fmter handler.Formatter) handler.Handler {
+	hndl := handler.NewBasicHandlerUsing(&tbWriter{t}, fmter)
+	// テストが終わった後に t.Log を呼ぶと panic するので。
+	t.Cleanup(func() { hndl.Close() })
+	return hndl
+}
+
+type tbWriter struct {
+	t testing.TB
+}
+
+func (w *tbWriter) Write(p []byte) (int, error) {
+	w.t.Log(strings.TrimSuffix(string(p), "\n"))
+	return len(p), nil
+}
@@ -0,0 +1,128 @@
+// Copyright 2015 realglobe, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package rglogtest
+
+import (
+	"errors"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/realglobe-Inc/go-lib/erro"
+	"github.com/realglobe-Inc/go-lib/rglog/handler"
+	"github.com/realglobe-Inc/go-lib/rglog/level"
+)
+
+// 失敗を記録するだけの testing.TB。
+type fakeTB struct {
+	testing.TB
+	lock     sync.Mutex
+	logs     []string
+	errs     []string
+	cleanups []func()
+}
+
+func (t *fakeTB) Helper() {}
+
+func (t *fakeTB) Log(args ...interface{}) {
+	t.lock.Lock()
+	defer t.lock.Unlock()
+	t.logs = append(t.logs, args[0].(string))
+}
+
+func (t *fakeTB) Errorf(format string, args ...interface{}) {
+	t.lock.Lock()
+	defer t.lock.Unlock()
+	t.errs = append(t.errs, format)
+}
+
+func (t *fakeTB) Cleanup(f func()) {
+	t.cleanups = append(t.cleanups, f)
+}
+
+func (t *fakeTB) finish() {
+	for i := len(t.cleanups) - 1; i >= 0; i-- {
+		t.cleanups[i]()
+	}
+}
+
+func TestCapture(t *testing.T) {
+	capt := New(t)
+	log := capt.Logger("a/b")
+	log.Info("started", handler.Field{Key: "id", Value: 1})
+	log.With("user", "x").Warn("slow request")
+	log.Debug("debug")
+
+	if rec := capt.Expect(Level(level.INFO), Message("^start"), Field("id", 1)); rec == nil {
+		t.Fatal(capt.Records())
+	} else if rec.LoggerName() != "a/b" {
+		t.Fatal(rec.LoggerName())
+	}
+	capt.Expect(LevelAtLeast(level.WARN), Field("user", "x"), LoggerName("a/b"))
+	capt.ExpectNone(Message("^fail"))
+	capt.ExpectNoErrors()
+	if recs := capt.Find(LevelAtLeast(level.INFO)); len(recs) != 2 {
+		t.Fatal(recs)
+	}
+
+	capt.Reset()
+	if recs := capt.Records(); len(recs) != 0 {
+		t.Fatal(recs)
+	}
+}
+
+func TestCaptureFailure(t *testing.T) {
+	tb := &fakeTB{}
+	capt := New(tb)
+	log := capt.Logger("a")
+	log.Info("test", handler.Field{Key: "id", Value: 1})
+	log.Warn("cannot open ", erro.New("test error"))
+
+	capt.Expect(Message("no such message"))
+	capt.Expect(Field("id", 2))
+	capt.ExpectNone(Level(level.INFO))
+	capt.ExpectNoErrors()
+	if len(tb.errs) != 4 {
+		t.Fatal(tb.errs)
+	}
+	if rec := capt.Expect(HasError()); rec == nil {
+		t.Fatal(tb.errs)
+	}
+
+	log.Err("failed ", errors.New("test error"))
+	tb.errs = nil
+	capt.Reset()
+	log.Err("failed")
+	capt.ExpectNoErrors()
+	if len(tb.errs) != 1 {
+		t.Fatal(tb.errs)
+	}
+	tb.finish()
+}
+
+func TestTBHandler(t *testing.T) {
+	tb := &fakeTB{}
+	hndl := NewTBHandlerUsing(tb, handler.LevelOnlyFormatter)
+	capt := New(t)
+	capt.Logger("").AddHandler("test", hndl)
+
+	capt.Logger("a").Info("before")
+	tb.finish()
+	capt.Logger("a").Info("after")
+
+	if len(tb.logs) != 1 || strings.HasSuffix(tb.logs[0], "\n") || !strings.Contains(tb.logs[0], "before") {
+		t.Fatal(tb.logs)
+	}
+}
@@ -0,0 +1,123 @@
+// Copyright 2015 realglobe, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package rglog
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"os/signal"
+	"sync"
+	"syscall"
+	"time"
+
+	"github.com/realglobe-Inc/go-lib/erro"
+	"github.com/realglobe-Inc/go-lib/rglog/handler"
+	"github.com/realglobe-Inc/go-lib/rglog/level"
+)
+
+// Shutdown, InstallShutdownHooks, RecoverAndFlush でハンドラの処理を待つ時間。
+var ShutdownTimeout = 5 * time.Second
+
+// 全てのハンドラを Flush して Close する。
+// ctx が終わるか ShutdownTimeout が経ったら、終わっていない処理を待たずに返る。
+// プログラムの終処理の最後に呼ぶ。
+func Shutdown(ctx context.Context) error {
+	ctx, cancel := context.WithTimeout(ctx, ShutdownTimeout)
+	defer cancel()
+	return Close(ctx)
+}
+
+// SIGINT か SIGTERM を受け取ったら、Shutdown で全てのハンドラを Flush して Close してから、
+// そのシグナルを既定の動作に戻して自分に送り直し、終了する。
+// 他で signal.Notify しているそのシグナルの受け取りも外れるので、
+// シグナルを受け取ってから自分の終処理をしたいプログラムは NotifyShutdown を使う。
+// 返り値の関数で外す。
+func InstallShutdownHooks() (uninstall func()) {
+	sigCh := make(chan os.Signal, 1)
+	signal.Notify(sigCh, syscall.SIGINT, syscall.SIGTERM)
+
+	done := make(chan struct{})
+	go func() {
+		select {
+		case <-done:
+			return
+		case sig := <-sigCh:
+			Shutdown(context.Background())
+
+			// 送り直したシグナルで終了するように。
+			signal.Reset(sig)
+			if sysSig, ok := sig.(syscall.Signal); ok {
+				syscall.Kill(os.Getpid(), sysSig)
+			}
+		}
+	}()
+
+	var once sync.Once
+	return func() {
+		once.Do(func() {
+			signal.Stop(sigCh)
+			close(done)
+		})
+	}
+}
+
+// SIGINT か SIGTERM を受け取ったら終わる context と、終処理の関数を返す。
+// InstallShutdownHooks と違って、シグナルを受け取っても、ハンドラを閉じたり終了したりはしない。
+// プログラムは sigCtx が終わったら自分の終処理をして、最後に shutdown を呼ぶ。
+// shutdown はシグナルの受け取りをやめてから Shutdown する。
+// 自分で signal.Notify しているプログラムは、これを使わずに、終処理の最後に Shutdown を呼べば良い。
+func NotifyShutdown() (sigCtx context.Context, shutdown func(ctx context.Context) error) {
+	sigCtx, stop := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+	return sigCtx, func(ctx context.Context) error {
+		stop()
+		return Shutdown(ctx)
+	}
+}
+
+// defer rglog.RecoverAndFlush() のように使う。
+// panic したら、"" のロガーに FATAL でログを取り、
+// 全てのハンドラを Flush してから、同じ値で panic し直す。
+// スタックトレースはメッセージには入れず、スタックトレース付きのエラーとしてレコードの Errors() に入れる。
+// ShutdownTimeout までしか Flush を待たない。
+func RecoverAndFlush() {
+	rcv := recover()
+	if rcv == nil {
+		return
+	}
+
+	msg := fmt.Sprint(rcv)
+	if err, ok := rcv.(error); ok {
+		// スタックトレース付きのエラーでも 1 行にする。
+		cause, _ := handler.ErrorStack(err)
+		msg = cause.Error()
+	}
+	mgr := Manager()
+	mgr.Logger("").Log(level.FATAL, "panic: ", erro.New(msg))
+
+	done := make(chan struct{})
+	go func() {
+		mgr.Flush()
+		close(done)
+	}()
+	timer := time.NewTimer(ShutdownTimeout)
+	select {
+	case <-done:
+	case <-timer.C:
+	}
+	timer.Stop()
+
+	panic(rcv)
+}
@@ -0,0 +1,159 @@
+// Copyright 2015 realglobe, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package rglog
+
+import (
+	"context"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/realglobe-Inc/go-lib/rglog/handler"
+	"github.com/realglobe-Inc/go-lib/rglog/level"
+	"github.com/realglobe-Inc/go-lib/rglog/logger"
+)
+
+// 子プロセスとして動かすときに、書き出すファイルを渡す環境変数。
+const shutdownTestEnv = "RGLOG_SHUTDOWN_TEST_FILE"
+
+func TestInstallShutdownHooks(t *testing.T) {
+	if path := os.Getenv(shutdownTestEnv); path != "" {
+		// 子プロセス。
+		log := newShutdownTestLogger(t, path)
+		InstallShutdownHooks()
+
+		log.Info("before signal")
+		syscall.Kill(os.Getpid(), syscall.SIGTERM)
+		time.Sleep(10 * time.Second)
+		t.Fatal("not terminated")
+	}
+
+	path := shutdownTestPath(t)
+	defer os.RemoveAll(filepath.Dir(path))
+	cmd := exec.Command(os.Args[0], "-test.run=^TestInstallShutdownHooks$")
+	cmd.Env = append(os.Environ(), shutdownTestEnv+"="+path)
+	err := cmd.Run()
+	// 同じシグナルで終了している。
+	exitErr, ok := err.(*exec.ExitError)
+	if !ok {
+		t.Fatal(err)
+	} else if status := exitErr.Sys().(syscall.WaitStatus); !status.Signaled() || status.Signal() != syscall.SIGTERM {
+		t.Fatal(status)
+	}
+
+	if buff, err := ioutil.ReadFile(path); err != nil {
+		t.Fatal(err)
+	} else if string(buff) != "[INF] before signal\n" {
+		t.Fatal(string(buff))
+	}
+}
+
+// 外したら、シグナルを受け取っても何もしない。
+func TestInstallShutdownHooksUninstall(t *testing.T) {
+	uninstall := InstallShutdownHooks()
+	uninstall()
+	uninstall()
+}
+
+func TestNotifyShutdown(t *testing.T) {
+	if path := os.Getenv(shutdownTestEnv); path != "" {
+		// 子プロセス。
+		log := newShutdownTestLogger(t, path)
+		sigCtx, shutdown := NotifyShutdown()
+
+		log.Info("before signal")
+		syscall.Kill(os.Getpid(), syscall.SIGTERM)
+		select {
+		case <-sigCtx.Done():
+		case <-time.After(10 * time.Second):
+			t.Fatal("no signal")
+		}
+		// シグナルを受け取った後の終処理のログも残る。
+		log.Info("after signal")
+		if err := shutdown(context.Background()); err != nil {
+			t.Fatal(err)
+		}
+		return
+	}
+
+	path := shutdownTestPath(t)
+	defer os.RemoveAll(filepath.Dir(path))
+	cmd := exec.Command(os.Args[0], "-test.run=^TestNotifyShutdown$")
+	cmd.Env = append(os.Environ(), shutdownTestEnv+"="+path)
+	if out, err := cmd.CombinedOutput(); err != nil {
+		t.Fatal(err, string(out))
+	}
+
+	if buff, err := ioutil.ReadFile(path); err != nil {
+		t.Fatal(err)
+	} else if string(buff) != "[INF] before signal\n[INF] after signal\n" {
+		t.Fatal(string(buff))
+	}
+}
+
+// 子プロセスで path に書き出すロガーをつくる。
+func newShutdownTestLogger(t *testing.T, path string) logger.Logger {
+	file, err := os.Create(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	log := Logger("shutdown")
+	log.SetLevel(level.INFO)
+	log.SetUseParent(false)
+	log.AddHandler("file", handler.NewCloseHandlerUsing(file, handler.LevelOnlyFormatter))
+	return log
+}
+
+// 子プロセスに書き出させるファイルのパスを返す。
+// 一時ディレクトリは呼び出し側で消す。
+func shutdownTestPath(t *testing.T) string {
+	dir, err := ioutil.TempDir("", "rglog")
+	if err != nil {
+		t.Fatal(err)
+	}
+	return filepath.Join(dir, "log")
+}
+
+func TestRecoverAndFlush(t *testing.T) {
+	var buff strings.Builder
+	hndl := handler.NewFlushHandlerUsing(&buff, handler.LevelOnlyFormatter)
+	root := Logger("")
+	defer root.SetHandlers(root.SetHandlers(map[string]handler.Handler{"test": hndl}))
+
+	func() {
+		defer func() {
+			if rcv := recover(); rcv != "abc" {
+				t.Fatal(rcv)
+			}
+		}()
+		defer RecoverAndFlush()
+		panic("abc")
+	}()
+
+	// スタックトレースは次の行から字下げして書かれる。
+	if s := buff.String(); !strings.HasPrefix(s, "[FAT] panic: abc\n\t") || !strings.Contains(s, "TestRecoverAndFlush") {
+		t.Fatal(s)
+	}
+
+	// panic しなければ何もしない。
+	func() {
+		defer RecoverAndFlush()
+	}()
+}